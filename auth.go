@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/andrei-himself/gator/internal/database"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+const sessionTTL = 30 * 24 * time.Hour
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readPassword prompts on stderr and reads a password without echoing it.
+// When stdin isn't a terminal the password is read as a plain line, so
+// scripts can pipe it in.
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readNewPassword asks for a new password twice and returns its bcrypt hash.
+func readNewPassword() (sql.NullString, error) {
+	password, err := readPassword("New password: ")
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	if password == "" {
+		return sql.NullString{}, fmt.Errorf("password can't be empty")
+	}
+	repeated, err := readPassword("Repeat password: ")
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	if password != repeated {
+		return sql.NullString{}, fmt.Errorf("passwords don't match")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return sql.NullString{}, fmt.Errorf("password is too long, use at most 72 bytes")
+	} else if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(hash), Valid: true}, nil
+}
+
+// verifyPassword prompts for the password of user, if they have one.
+func verifyPassword(user database.User, prompt string) error {
+	if !user.PasswordHash.Valid {
+		return nil
+	}
+	password, err := readPassword(prompt)
+	if err != nil {
+		return err
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return fmt.Errorf("wrong password for user '%s'", user.Name)
+	}
+	return err
+}
+
+// saveLogin makes user the current user in the config file. Users with a
+// password also get a new session token; only its hash is kept in the
+// database.
+func saveLogin(s *state, user database.User) error {
+	s.sessionUser = ""
+	if !user.PasswordHash.Valid {
+		return s.cfg.SetUser(user.Name)
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	now := time.Now()
+	err := s.db.CreateSession(context.Background(), database.CreateSessionParams{
+		TokenHash : hashToken(token),
+		UserID : user.ID,
+		CreatedAt : now,
+		ExpiresAt : now.Add(sessionTTL),
+	})
+	if err != nil {
+		return err
+	}
+	return s.cfg.SetSession(user.Name, token)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// authorize checks that the current user may act as user: either they have
+// no password, they were switched to with a verified su in the shell, or the
+// config holds a valid session token for them.
+func authorize(s *state, user database.User) error {
+	if !user.PasswordHash.Valid || s.sessionUser == user.Name {
+		return nil
+	}
+	denied := fmt.Errorf("user '%s' is password protected, run 'gator login %s' first", user.Name, user.Name)
+	if s.cfg.SessionToken == "" {
+		return denied
+	}
+	session, err := s.db.GetSession(context.Background(), hashToken(s.cfg.SessionToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		return denied
+	} else if err != nil {
+		return err
+	}
+	if session.UserID != user.ID || time.Now().After(session.ExpiresAt) {
+		return denied
+	}
+	return nil
+}
+
+func handlerPasswd(s *state, cmd command, user database.User) error {
+	if err := verifyPassword(user, "Current password: "); err != nil {
+		return err
+	}
+	remove := cmd.boolFlag("remove")
+	if remove && !user.PasswordHash.Valid {
+		return fmt.Errorf("user '%s' has no password", user.Name)
+	}
+	var hash sql.NullString
+	if !remove {
+		var err error
+		hash, err = readNewPassword()
+		if err != nil {
+			return err
+		}
+	}
+	err := s.db.SetUserPassword(context.Background(), database.SetUserPasswordParams{
+		ID : user.ID,
+		PasswordHash : hash,
+		UpdatedAt : time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	// log out everywhere else; this session keeps going with a new token
+	if err := s.db.DeleteSessionsForUser(context.Background(), user.ID); err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	if s.sessionUser == "" {
+		if err := saveLogin(s, user); err != nil {
+			return err
+		}
+	}
+	if remove {
+		fmt.Printf("Password removed for user '%s'\n", user.Name)
+	} else {
+		fmt.Printf("Password changed for user '%s'\n", user.Name)
+	}
+	return nil
+}
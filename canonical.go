@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andrei-himself/gator/internal/canonical"
+	"github.com/andrei-himself/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// canonicalURL normalizes a feed or post URL, stripping the tracking
+// parameters configured for the active profile.
+func canonicalURL(s *state, rawURL string) (string, error) {
+	return canonical.URL(rawURL, canonical.ParseParams(s.cfg.TrackingParams))
+}
+
+// getFeedByURL looks up a feed by its URL or one of the usual variants of
+// it, such as http instead of https. It returns sql.ErrNoRows if there is no
+// such feed.
+func getFeedByURL(s *state, rawURL string) (database.Feed, error) {
+	url, err := canonicalURL(s, rawURL)
+	if err != nil {
+		return database.Feed{}, err
+	}
+	return s.db.GetFeedByURLs(context.Background(), canonical.Variants(url))
+}
+
+// handlerCanonicalize rewrites the stored feed and post URLs in canonical
+// form, for rows saved before URLs were canonicalized or with other tracking
+// parameters configured. URLs that turn out to be duplicates are merged.
+func handlerCanonicalize(s *state, cmd command, user database.User) error {
+	feeds, err := s.db.GetFeeds(context.Background())
+	if err != nil {
+		return err
+	}
+	feedsUpdated, feedsMerged := 0, 0
+	for _, feed := range feeds {
+		url, err := canonicalURL(s, feed.Url)
+		if err != nil {
+			fmt.Printf("Skipping feed '%s': %v\n", feed.Name, err)
+			continue
+		}
+		if url == feed.Url {
+			continue
+		}
+		target, merged, err := moveFeed(s, feed, url)
+		if err != nil {
+			return err
+		}
+		if merged {
+			fmt.Printf("Feed '%s' merged into '%s' at %s\n", feed.Name, target.Name, url)
+			feedsMerged++
+		} else {
+			fmt.Printf("Feed '%s' now uses %s\n", feed.Name, url)
+			feedsUpdated++
+		}
+	}
+
+	posts, err := s.db.GetPostURLs(context.Background())
+	if err != nil {
+		return err
+	}
+	postsUpdated, postsMerged := 0, 0
+	for _, post := range posts {
+		url, err := canonicalURL(s, post.Url)
+		if err != nil || url == post.Url {
+			continue
+		}
+		merged, err := canonicalizePost(s, post.ID, url)
+		if err != nil {
+			return err
+		}
+		if merged {
+			postsMerged++
+		} else {
+			postsUpdated++
+		}
+	}
+	fmt.Printf("Feeds: %d updated, %d merged. Posts: %d updated, %d merged\n", feedsUpdated, feedsMerged, postsUpdated, postsMerged)
+	return nil
+}
+
+// canonicalizePost sets the URL of a post to url. If another post already
+// has it, the post is merged into that one: its feeds and read state move
+// over and it is deleted.
+func canonicalizePost(s *state, id uuid.UUID, url string) (bool, error) {
+	ctx := context.Background()
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	q := s.db.WithTx(tx)
+
+	other, err := q.GetPostByURLs(ctx, canonical.Variants(url))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && other.ID == id) {
+		err = q.SetPostURL(ctx, database.SetPostURLParams{
+			ID : id,
+			Url : url,
+			UpdatedAt : time.Now(),
+		})
+		if err != nil {
+			return false, err
+		}
+		return false, tx.Commit()
+	} else if err != nil {
+		return false, err
+	}
+
+	err = q.MovePostFeeds(ctx, database.MovePostFeedsParams{ToPostID : other.ID, FromPostID : id})
+	if err != nil {
+		return false, err
+	}
+	err = q.MovePostReads(ctx, database.MovePostReadsParams{ToPostID : other.ID, FromPostID : id})
+	if err != nil {
+		return false, err
+	}
+	if err := q.DeletePost(ctx, id); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+type command struct {
+	name  string
+	args  []string
+	flags map[string]string
+}
+
+// flag returns the value of a flag passed on the command line, or "" if it wasn't set.
+func (c command) flag(name string) string {
+	return c.flags[name]
+}
+
+func (c command) hasFlag(name string) bool {
+	_, ok := c.flags[name]
+	return ok
+}
+
+func (c command) boolFlag(name string) bool {
+	v, ok := c.flags[name]
+	return ok && v != "false"
+}
+
+// flagDef describes a --flag accepted by a command. Flags with an empty
+// value placeholder are booleans.
+type flagDef struct {
+	name    string
+	value   string
+	usage   string
+	check   func(string) error
+	choices []string
+	apply   func(*state, string) error
+}
+
+// commandDef describes a registered command. Positional args are declared as
+// "name" (required), "[name]" (optional), "name..." (one or more) or
+// "[name...]" (any number). Commands with rawArgs get their arguments
+// untouched, without flag parsing or validation. Offline commands run
+// without reading the config or opening the database.
+type commandDef struct {
+	name        string
+	args        []string
+	flags       []flagDef
+	description string
+	hidden      bool
+	rawArgs     bool
+	offline     bool
+	handler     func(*state, command) error
+	complete    func(*state, []string) []string
+	subcommands []commandDef
+}
+
+type commands struct {
+	m       map[string]commandDef
+	names   []string
+	globals []flagDef
+}
+
+var helpFlag = flagDef{name: "help", usage: "Show help for this command"}
+
+func (c *commands) run(s *state, cmd command) error {
+	def, ok := c.m[cmd.name]
+	if ok != true {
+		if suggestion := c.suggest(cmd.name); suggestion != "" {
+			return fmt.Errorf("command '%v' not found, did you mean '%v'?", cmd.name, suggestion)
+		}
+		return fmt.Errorf("command '%v' not found, run 'gator help' for a list of commands", cmd.name)
+	}
+	return c.dispatch(s, def, cmd.args)
+}
+
+// offline reports whether cmd can run without config and database: it is
+// unknown, marked offline or only asks for its help.
+func (c *commands) offline(cmd command) bool {
+	def, ok := c.m[cmd.name]
+	if !ok {
+		return true
+	}
+	args := cmd.args
+	for len(args) > 0 {
+		sub, ok := def.subcommand(args[0])
+		if !ok {
+			break
+		}
+		def = sub
+		args = args[1:]
+	}
+	if def.offline {
+		return true
+	}
+	if def.rawArgs {
+		return false
+	}
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg == "-h" || arg == "--help" || arg == "--help=true" {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch descends into subcommands named by the leading args, then parses
+// and validates the rest before calling the handler.
+func (c *commands) dispatch(s *state, def commandDef, args []string) error {
+	if len(args) > 0 {
+		if sub, ok := def.subcommand(args[0]); ok {
+			return c.dispatch(s, sub, args[1:])
+		}
+	}
+	if def.rawArgs {
+		return def.handler(s, command{name: def.name, args: args, flags: map[string]string{}})
+	}
+	parsed, err := c.parseArgs(def, args)
+	if err != nil {
+		return fmt.Errorf("%v\nusage: %v", err, def.usage())
+	}
+	if parsed.boolFlag("help") {
+		c.printCommandHelp(os.Stdout, def)
+		return nil
+	}
+	if def.handler == nil {
+		if len(parsed.args) == 0 {
+			return fmt.Errorf("%s command expects one of: %s\nusage: %v", def.name, strings.Join(def.subcommandNames(), ", "), def.usage())
+		}
+		return fmt.Errorf("unknown subcommand '%s' for %s command, expected one of: %s", parsed.args[0], def.name, strings.Join(def.subcommandNames(), ", "))
+	}
+	if err := def.validate(parsed.args); err != nil {
+		return fmt.Errorf("%v\nusage: %v", err, def.usage())
+	}
+	for _, f := range c.globals {
+		if f.apply != nil && parsed.hasFlag(f.name) && !def.offline {
+			if err := f.apply(s, parsed.flag(f.name)); err != nil {
+				return err
+			}
+		}
+	}
+	return def.handler(s, parsed)
+}
+
+// subcommand looks up a subcommand by name. The returned definition carries
+// the full name, e.g. "config get".
+func (def commandDef) subcommand(name string) (commandDef, bool) {
+	for _, sub := range def.subcommands {
+		if sub.name == name {
+			sub.name = def.name + " " + sub.name
+			return sub, true
+		}
+	}
+	return commandDef{}, false
+}
+
+func (def commandDef) subcommandNames() []string {
+	var names []string
+	for _, sub := range def.subcommands {
+		if !sub.hidden {
+			names = append(names, sub.name)
+		}
+	}
+	return names
+}
+
+// registerGlobal adds a flag accepted by every command, either before or
+// after the command name.
+func (c *commands) registerGlobal(f flagDef) {
+	c.globals = append(c.globals, f)
+}
+
+func (c *commands) register(def commandDef) {
+	if _, ok := c.m[def.name]; !ok {
+		c.names = append(c.names, def.name)
+	}
+	c.m[def.name] = def
+}
+
+func (c *commands) handlerHelp(s *state, cmd command) error {
+	if len(cmd.args) == 0 {
+		c.printHelp(os.Stdout)
+		return nil
+	}
+	def, ok := c.m[cmd.args[0]]
+	if !ok {
+		return c.run(s, command{name: cmd.args[0]})
+	}
+	for _, name := range cmd.args[1:] {
+		sub, ok := def.subcommand(name)
+		if !ok {
+			return fmt.Errorf("unknown subcommand '%s' for %s command, expected one of: %s", name, def.name, strings.Join(def.subcommandNames(), ", "))
+		}
+		def = sub
+	}
+	c.printCommandHelp(os.Stdout, def)
+	return nil
+}
+
+func (c *commands) printHelp(w io.Writer) {
+	fmt.Fprintln(w, "gator - a CLI blog aggregator")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage: gator <command> [args...]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 3, ' ', 0)
+	for _, name := range c.names {
+		def := c.m[name]
+		if def.hidden {
+			continue
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", strings.TrimPrefix(def.usage(), "gator "), def.description)
+	}
+	tw.Flush()
+	printFlags(w, "Global flags:", c.globals)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'gator help <command>' or 'gator <command> --help' for details.")
+}
+
+// suggest returns the registered command closest to name, or "" if nothing is close enough.
+func (c *commands) suggest(name string) string {
+	best := ""
+	bestDist := 3
+	names := append([]string{}, c.names...)
+	sort.Strings(names)
+	for _, candidate := range names {
+		if c.m[candidate].hidden {
+			continue
+		}
+		d := levenshtein(name, candidate)
+		if strings.HasPrefix(candidate, name) && len(name) >= 2 {
+			d = 1
+		}
+		if d < bestDist {
+			best = candidate
+			bestDist = d
+		}
+	}
+	return best
+}
+
+func (def commandDef) usage() string {
+	parts := []string{"gator", def.name}
+	if len(def.subcommands) > 0 {
+		if def.handler == nil {
+			return strings.Join(append(parts, "<subcommand>"), " ")
+		}
+		parts = append(parts, "[subcommand]")
+	}
+	if len(def.flags) > 0 {
+		parts = append(parts, "[flags]")
+	}
+	for _, a := range def.args {
+		switch {
+		case strings.HasPrefix(a, "["):
+			parts = append(parts, a)
+		case strings.HasSuffix(a, "..."):
+			parts = append(parts, "<"+strings.TrimSuffix(a, "...")+">...")
+		default:
+			parts = append(parts, "<"+a+">")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (c *commands) printCommandHelp(w io.Writer, def commandDef) {
+	fmt.Fprintf(w, "Usage: %s\n", def.usage())
+	if def.description != "" {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, def.description)
+	}
+	if len(def.subcommands) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Subcommands:")
+		tw := tabwriter.NewWriter(w, 0, 4, 3, ' ', 0)
+		for _, sub := range def.subcommands {
+			if sub.hidden {
+				continue
+			}
+			sub.name = def.name + " " + sub.name
+			fmt.Fprintf(tw, "  %s\t%s\n", strings.TrimPrefix(sub.usage(), "gator "), sub.description)
+		}
+		tw.Flush()
+	}
+	printFlags(w, "Flags:", append(def.flags[:len(def.flags):len(def.flags)], helpFlag))
+	printFlags(w, "Global flags:", c.globals)
+}
+
+func printFlags(w io.Writer, title string, flags []flagDef) {
+	if len(flags) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, title)
+	tw := tabwriter.NewWriter(w, 0, 4, 3, ' ', 0)
+	for _, f := range flags {
+		name := "--" + f.name
+		if f.value != "" {
+			name += " <" + f.value + ">"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", name, f.usage)
+	}
+	tw.Flush()
+}
+
+func (c *commands) lookupFlag(def commandDef, name string) (flagDef, bool) {
+	if name == helpFlag.name {
+		return helpFlag, true
+	}
+	for _, f := range def.flags {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range c.globals {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return flagDef{}, false
+}
+
+// splitArgs returns the command name and its arguments, moving any global
+// flags given before the command name after it.
+func (c *commands) splitArgs(raw []string) (string, []string) {
+	var leading []string
+	for i := 0; i < len(raw); i++ {
+		arg := raw[i]
+		if arg == "-h" || arg == "--help" {
+			return "help", raw[i+1:]
+		}
+		if !strings.HasPrefix(arg, "--") {
+			return arg, append(raw[i+1:len(raw):len(raw)], leading...)
+		}
+		leading = append(leading, arg)
+		name, _, hasValue := strings.Cut(arg[2:], "=")
+		f, ok := c.lookupFlag(commandDef{}, name)
+		if ok && f.value != "" && !hasValue && i+1 < len(raw) {
+			i++
+			leading = append(leading, raw[i])
+		}
+	}
+	return "", leading
+}
+
+// parseArgs splits raw arguments into flags and positional args. Flags may
+// appear anywhere; everything after "--" is positional.
+func (c *commands) parseArgs(def commandDef, raw []string) (command, error) {
+	cmd := command{
+		name : def.name,
+		args : []string{},
+		flags : map[string]string{},
+	}
+	for i := 0; i < len(raw); i++ {
+		arg := raw[i]
+		if arg == "--" {
+			cmd.args = append(cmd.args, raw[i+1:]...)
+			break
+		}
+		if arg == "-h" {
+			cmd.flags["help"] = "true"
+			continue
+		}
+		if !strings.HasPrefix(arg, "--") || len(arg) == 2 {
+			cmd.args = append(cmd.args, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(arg[2:], "=")
+		f, ok := c.lookupFlag(def, name)
+		if !ok {
+			return command{}, fmt.Errorf("unknown flag --%s for command '%s'", name, def.name)
+		}
+		if f.value == "" {
+			if !hasValue {
+				value = "true"
+			}
+		} else if !hasValue {
+			if i+1 >= len(raw) {
+				return command{}, fmt.Errorf("flag --%s requires a value", name)
+			}
+			i++
+			value = raw[i]
+		}
+		if f.check != nil {
+			if err := f.check(value); err != nil {
+				return command{}, err
+			}
+		}
+		cmd.flags[name] = value
+	}
+	return cmd, nil
+}
+
+func (def commandDef) validate(args []string) error {
+	required, optional, variadic := 0, 0, false
+	for _, a := range def.args {
+		switch {
+		case strings.HasPrefix(a, "["):
+			optional++
+			variadic = variadic || strings.HasSuffix(a, "...]")
+		case strings.HasSuffix(a, "..."):
+			required++
+			variadic = true
+		default:
+			required++
+		}
+	}
+	if len(args) < required {
+		missing := def.args[len(args)]
+		return fmt.Errorf("%s command expects %s as an argument", def.name, strings.TrimSuffix(missing, "..."))
+	}
+	if !variadic && len(args) > required+optional {
+		return fmt.Errorf("too many arguments for %s command", def.name)
+	}
+	return nil
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
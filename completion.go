@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/andrei-himself/gator/internal/config"
+)
+
+// completions returns candidates for the last of words, which is the word
+// being typed and may be empty. The first word is the command name.
+func (c *commands) completions(s *state, words []string) []string {
+	// global flags may come before the command name
+	for len(words) > 1 && strings.HasPrefix(words[0], "--") {
+		name, _, hasValue := strings.Cut(words[0][2:], "=")
+		f, ok := c.lookupFlag(commandDef{}, name)
+		if ok && f.value != "" && !hasValue {
+			if len(words) == 2 {
+				return filterPrefix(f.choices, words[1])
+			}
+			words = words[1:]
+		}
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return nil
+	}
+	partial := words[len(words)-1]
+	def, ok := c.m[words[0]]
+	if len(words) == 1 {
+		if strings.HasPrefix(partial, "--") {
+			return c.completeFlags(commandDef{}, partial)
+		}
+		return filterPrefix(c.visibleNames(), partial)
+	}
+	if !ok {
+		return nil
+	}
+	for len(words) > 2 {
+		sub, ok := def.subcommand(words[1])
+		if !ok {
+			break
+		}
+		def = sub
+		words = words[1:]
+	}
+	if strings.HasPrefix(partial, "--") {
+		return c.completeFlags(def, partial)
+	}
+	if len(words) == 2 && len(def.subcommands) > 0 {
+		candidates := def.subcommandNames()
+		if def.complete != nil {
+			candidates = append(candidates, def.complete(s, nil)...)
+		}
+		return filterPrefix(candidates, partial)
+	}
+
+	var positional []string
+	for i := 1; i < len(words)-1; i++ {
+		word := words[i]
+		if !strings.HasPrefix(word, "--") || word == "--" {
+			positional = append(positional, word)
+			continue
+		}
+		name, _, hasValue := strings.Cut(word[2:], "=")
+		if f, ok := c.lookupFlag(def, name); ok && f.value != "" && !hasValue {
+			i++
+		}
+	}
+	if prev := words[len(words)-2]; strings.HasPrefix(prev, "--") && !strings.Contains(prev, "=") {
+		if f, ok := c.lookupFlag(def, prev[2:]); ok && f.value != "" {
+			return filterPrefix(f.choices, partial)
+		}
+	}
+	if def.complete == nil {
+		return nil
+	}
+	return filterPrefix(def.complete(s, positional), partial)
+}
+
+func (c *commands) completeFlags(def commandDef, partial string) []string {
+	if name, value, ok := strings.Cut(partial[2:], "="); ok {
+		f, found := c.lookupFlag(def, name)
+		if !found {
+			return nil
+		}
+		var values []string
+		for _, choice := range filterPrefix(f.choices, value) {
+			values = append(values, "--"+name+"="+choice)
+		}
+		return values
+	}
+	var names []string
+	for _, f := range append(append([]flagDef{helpFlag}, def.flags...), c.globals...) {
+		names = append(names, "--"+f.name)
+	}
+	return filterPrefix(names, partial)
+}
+
+func (c *commands) visibleNames() []string {
+	var names []string
+	for _, name := range c.names {
+		if !c.m[name].hidden {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// completeCommandNames completes the first argument of commands like help.
+func (c *commands) completeCommandNames(s *state, args []string) []string {
+	if len(args) > 0 {
+		return nil
+	}
+	return c.visibleNames()
+}
+
+func completeUsernames(s *state, args []string) []string {
+	if len(args) > 0 || s.db == nil {
+		return nil
+	}
+	users, err := s.db.GetUsers(context.Background())
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, u := range users {
+		names = append(names, u.Name)
+	}
+	return names
+}
+
+func completeFeedURLs(s *state, args []string) []string {
+	if len(args) > 0 || s.db == nil {
+		return nil
+	}
+	feeds, err := s.db.GetFeeds(context.Background())
+	if err != nil {
+		return nil
+	}
+	var urls []string
+	for _, f := range feeds {
+		urls = append(urls, f.Url)
+	}
+	return urls
+}
+
+func completeFollowedFeedURLs(s *state, args []string) []string {
+	if len(args) > 0 || s.db == nil {
+		return nil
+	}
+	user, err := s.db.GetUser(context.Background(), s.currentUserName())
+	if err != nil {
+		return nil
+	}
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil
+	}
+	var urls []string
+	for _, f := range follows {
+		urls = append(urls, f.FeedUrl)
+	}
+	return urls
+}
+
+// completeFolders completes a followed feed, then the folders already in use.
+func completeFolders(s *state, args []string) []string {
+	if len(args) == 0 {
+		return completeFollowedFeedURLs(s, args)
+	}
+	if len(args) > 1 || s.db == nil {
+		return nil
+	}
+	user, err := s.db.GetUser(context.Background(), s.currentUserName())
+	if err != nil {
+		return nil
+	}
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil
+	}
+	var folders []string
+	for _, f := range follows {
+		if f.Folder.Valid && !slices.Contains(folders, f.Folder.String) {
+			folders = append(folders, f.Folder.String)
+		}
+	}
+	return folders
+}
+
+func filterPrefix(candidates []string, prefix string) []string {
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			matches = append(matches, c)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// handlerComplete is the hidden entry point used by the shell completion
+// scripts. It prints one candidate per line for the last argument. It runs
+// on every tab press, so it never creates a config file or prints errors:
+// without a config or database it only completes commands and flags.
+func (c *commands) handlerComplete(s *state, cmd command) error {
+	if s.cfg == nil {
+		if conf, err := config.ReadExisting(); err == nil {
+			s.cfg = &conf
+			if err := s.connect(); err == nil {
+				defer s.conn.Close()
+			}
+		}
+	}
+	words := cmd.args
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	for _, candidate := range c.completions(s, words) {
+		fmt.Println(candidate)
+	}
+	return nil
+}
+
+func handlerCompletion(s *state, cmd command) error {
+	script, ok := completionScripts[cmd.args[0]]
+	if !ok {
+		return fmt.Errorf("unsupported shell '%s', expected one of bash, zsh or fish", cmd.args[0])
+	}
+	fmt.Print(script)
+	return nil
+}
+
+func completeShells(s *state, args []string) []string {
+	if len(args) > 0 {
+		return nil
+	}
+	return []string{"bash", "fish", "zsh"}
+}
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for gator
+# Add to ~/.bashrc:  source <(gator completion bash)
+
+_gator_completion() {
+    local cur words cword
+    if declare -F _get_comp_words_by_ref >/dev/null 2>&1; then
+        _get_comp_words_by_ref -n =: cur words cword
+    else
+        cur="${COMP_WORDS[COMP_CWORD]}"
+        words=("${COMP_WORDS[@]}")
+        cword=$COMP_CWORD
+    fi
+    local IFS=$'\n'
+    COMPREPLY=($(gator __complete "${words[@]:1:cword}" 2>/dev/null))
+    if declare -F __ltrim_colon_completions >/dev/null 2>&1; then
+        __ltrim_colon_completions "$cur"
+    fi
+}
+
+complete -F _gator_completion gator
+`,
+	"zsh": `#compdef gator
+# zsh completion for gator
+# Add to ~/.zshrc:  source <(gator completion zsh)
+# or save as _gator in a directory on your $fpath.
+
+_gator() {
+    local -a candidates
+    candidates=("${(@f)$(gator __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    candidates=(${candidates:#})
+    compadd -Q -- "${candidates[@]}"
+}
+
+if [ "$funcstack[1]" = "_gator" ]; then
+    _gator "$@"
+else
+    compdef _gator gator
+fi
+`,
+	"fish": `# fish completion for gator
+# Save to ~/.config/fish/completions/gator.fish:  gator completion fish > ~/.config/fish/completions/gator.fish
+
+function __gator_complete
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    gator __complete $tokens (commandline -ct) 2>/dev/null
+end
+
+complete -c gator -f -a '(__gator_complete)'
+`,
+}
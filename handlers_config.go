@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/andrei-himself/gator/internal/config"
+	"github.com/andrei-himself/gator/internal/output"
+)
+
+var configEnv = map[string]string{
+	"db_url" : config.EnvDBURL,
+	"current_user_name" : config.EnvUser,
+}
+
+func handlerConfigGet(s *state, cmd command) error {
+	if len(cmd.args) > 0 {
+		value, err := s.cfg.Get(cmd.args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+	}
+	table := output.Table{
+		Columns : []output.Column{
+			{Name : "key"},
+			{Name : "value"},
+			{Name : "source"},
+		},
+	}
+	for _, key := range config.Keys {
+		value, err := s.cfg.Get(key)
+		if err != nil {
+			return err
+		}
+		source := "file"
+		if env := configEnv[key]; env != "" && os.Getenv(env) != "" {
+			source = "env " + env
+		} else if value == "" && (strings.HasPrefix(key, "fetch.") || key == "tracking_params") {
+			source = "default"
+		}
+		table.Add(key, value, source)
+	}
+	return printTable(cmd, table)
+}
+
+func handlerConfigSet(s *state, cmd command) error {
+	key, value := cmd.args[0], cmd.args[1]
+	if err := s.cfg.Set(key, value); err != nil {
+		return err
+	}
+	fmt.Printf("Set %s in %s\n", key, s.cfg.Path())
+	if env := configEnv[key]; env != "" && os.Getenv(env) != "" {
+		fmt.Printf("Note: %s is set and overrides this value\n", env)
+	}
+	return nil
+}
+
+func handlerConfigPath(s *state, cmd command) error {
+	fmt.Println(s.cfg.Path())
+	return nil
+}
+
+func handlerConfigValidate(s *state, cmd command) error {
+	fmt.Printf("Profile %s in %s\n", s.cfg.ActiveProfile(), s.cfg.Path())
+	var problems []string
+	check := func(name string, err error) {
+		if err != nil {
+			problems = append(problems, name)
+			fmt.Printf("FAIL  %s: %v\n", name, err)
+			return
+		}
+		fmt.Printf("ok    %s\n", name)
+	}
+
+	check("db_url is a postgres URL", validateDBURL(s.cfg.DBURL))
+	check("fetch settings are valid", configureFetch(s.cfg))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := s.conn.PingContext(ctx)
+	check("database is reachable", err)
+
+	if s.cfg.CurrentUserName != "" && err == nil {
+		_, err := s.db.GetUser(context.Background(), s.cfg.CurrentUserName)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = fmt.Errorf("user '%s' doesn't exist in the database", s.cfg.CurrentUserName)
+		}
+		check("current user exists", err)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("config %s has %d problem(s)", s.cfg.Path(), len(problems))
+	}
+	return nil
+}
+
+func validateDBURL(dbURL string) error {
+	if strings.TrimSpace(dbURL) == "" {
+		return fmt.Errorf("db_url is empty")
+	}
+	// lib/pq also accepts "host=... dbname=..." connection strings
+	if !strings.Contains(dbURL, "://") {
+		if !strings.Contains(dbURL, "=") {
+			return fmt.Errorf("'%s' is neither a URL nor a key=value connection string", dbURL)
+		}
+		return nil
+	}
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return fmt.Errorf("unsupported scheme '%s', expected postgres://", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host")
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		return fmt.Errorf("missing database name")
+	}
+	return nil
+}
+
+func completeConfigKeys(s *state, args []string) []string {
+	if len(args) > 0 {
+		return nil
+	}
+	return config.Keys
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andrei-himself/gator/internal/database"
+	"github.com/andrei-himself/gator/internal/rss"
+)
+
+// getManagedFeed looks up a feed that user may change: one they own, or any
+// feed if they are an admin.
+func getManagedFeed(s *state, url string, user database.User) (database.Feed, error) {
+	feed, err := getFeedByURL(s, url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Feed{}, fmt.Errorf("feed '%s' doesn't exist in the database", url)
+	} else if err != nil {
+		return database.Feed{}, err
+	}
+	if feed.UserID != user.ID && !user.IsAdmin {
+		return database.Feed{}, fmt.Errorf("feed '%s' belongs to another user, only its owner or an admin can change it", url)
+	}
+	return feed, nil
+}
+
+func handlerFeedRemove(s *state, cmd command, user database.User) error {
+	feed, err := getManagedFeed(s, cmd.args[0], user)
+	if err != nil {
+		return err
+	}
+	if err := s.db.DeleteFeed(context.Background(), feed.ID); err != nil {
+		return err
+	}
+	// posts shared with other feeds stay
+	if err := s.db.DeleteOrphanPosts(context.Background()); err != nil {
+		return err
+	}
+	fmt.Printf("Feed '%s' removed along with its follows and posts no other feed has\n", feed.Name)
+	return nil
+}
+
+func handlerFeedRename(s *state, cmd command, user database.User) error {
+	feed, err := getManagedFeed(s, cmd.args[0], user)
+	if err != nil {
+		return err
+	}
+	err = s.db.RenameFeed(context.Background(), database.RenameFeedParams{
+		ID : feed.ID,
+		Name : cmd.args[1],
+		UpdatedAt : time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Feed '%s' renamed to '%s'\n", feed.Name, cmd.args[1])
+	return nil
+}
+
+// credentialsStay reports whether credentials given for oldURL may be sent
+// to newURL: the host must be the same and https must not become http.
+func credentialsStay(oldURL, newURL string) bool {
+	if !sameHost(oldURL, newURL) {
+		return false
+	}
+	from, err := url.Parse(oldURL)
+	if err != nil {
+		return false
+	}
+	to, err := url.Parse(newURL)
+	if err != nil {
+		return false
+	}
+	return !strings.EqualFold(from.Scheme, "https") || strings.EqualFold(to.Scheme, "https")
+}
+
+// hasCredentials reports whether feed has stored credentials.
+func hasCredentials(s *state, feed database.Feed) (bool, error) {
+	_, err := s.db.GetFeedCredentials(context.Background(), feed.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// updateFeed runs change on feed in a transaction and, with clear set, also
+// removes the feed's credentials.
+func updateFeed(s *state, feed database.Feed, clear bool, change func(q *database.Queries) error) error {
+	ctx := context.Background()
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := s.db.WithTx(tx)
+	if err := change(q); err != nil {
+		return err
+	}
+	if clear {
+		if err := q.DeleteFeedCredentials(ctx, feed.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func handlerFeedSetURL(s *state, cmd command, user database.User) error {
+	feed, err := getManagedFeed(s, cmd.args[0], user)
+	if err != nil {
+		return err
+	}
+	newURL, err := canonicalURL(s, cmd.args[1])
+	if err != nil {
+		return err
+	}
+	if err := checkFeedURL(s, newURL); err != nil {
+		return err
+	}
+	other, err := getFeedByURL(s, newURL)
+	if err == nil && other.ID != feed.ID {
+		return fmt.Errorf("feed '%s' already uses '%s'", other.Name, other.Url)
+	} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	// credentials were given for the old host, so they don't follow the
+	// feed elsewhere unless asked to
+	clear := false
+	if !cmd.boolFlag("keep-credentials") && !credentialsStay(feed.Url, newURL) {
+		clear, err = hasCredentials(s, feed)
+		if err != nil {
+			return err
+		}
+	}
+	err = updateFeed(s, feed, clear, func(q *database.Queries) error {
+		return q.SetFeedURL(context.Background(), database.SetFeedURLParams{
+			ID : feed.ID,
+			Url : newURL,
+			UpdatedAt : time.Now(),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Feed '%s' now fetches from %s\n", feed.Name, newURL)
+	if clear {
+		fmt.Println("Its credentials were cleared because the new URL is on another host or isn't https, add them again with 'gator feed auth'")
+	}
+	return nil
+}
+
+func handlerFeedTransfer(s *state, cmd command, user database.User) error {
+	feed, err := getManagedFeed(s, cmd.args[0], user)
+	if err != nil {
+		return err
+	}
+	owner, err := getUser(s, cmd.args[1])
+	if err != nil {
+		return err
+	}
+	// the new owner could point the feed and its credentials anywhere, so
+	// they stay only if asked to
+	clear := false
+	if !cmd.boolFlag("keep-credentials") && owner.ID != feed.UserID {
+		clear, err = hasCredentials(s, feed)
+		if err != nil {
+			return err
+		}
+	}
+	err = updateFeed(s, feed, clear, func(q *database.Queries) error {
+		return q.SetFeedOwner(context.Background(), database.SetFeedOwnerParams{
+			ID : feed.ID,
+			UserID : owner.ID,
+			UpdatedAt : time.Now(),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Feed '%s' now belongs to '%s'\n", feed.Name, owner.Name)
+	if clear {
+		fmt.Println("Its credentials were cleared, the new owner can add their own with 'gator feed auth'")
+	}
+	return nil
+}
+
+func handlerFeedPause(s *state, cmd command, user database.User) error {
+	feed, err := getManagedFeed(s, cmd.args[0], user)
+	if err != nil {
+		return err
+	}
+	if feed.PausedAt.Valid {
+		return fmt.Errorf("feed '%s' is already paused", feed.Name)
+	}
+	reason := cmd.flag("reason")
+	if reason == "" {
+		reason = "paused by " + user.Name
+	}
+	err = s.db.PauseFeed(context.Background(), database.PauseFeedParams{
+		ID : feed.ID,
+		PausedAt : sql.NullTime{Time: time.Now(), Valid: true},
+		PauseReason : sql.NullString{String: reason, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Feed '%s' paused\n", feed.Name)
+	return nil
+}
+
+func handlerFeedResume(s *state, cmd command, user database.User) error {
+	feed, err := getManagedFeed(s, cmd.args[0], user)
+	if err != nil {
+		return err
+	}
+	if !feed.PausedAt.Valid {
+		return fmt.Errorf("feed '%s' isn't paused", feed.Name)
+	}
+	err = s.db.ResumeFeed(context.Background(), database.ResumeFeedParams{
+		ID : feed.ID,
+		UpdatedAt : time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Feed '%s' resumed\n", feed.Name)
+	return nil
+}
+
+func handlerFeedInterval(s *state, cmd command, user database.User) error {
+	feed, err := getManagedFeed(s, cmd.args[0], user)
+	if err != nil {
+		return err
+	}
+	var interval sql.NullInt32
+	next := feed.NextFetchAt
+	if cmd.args[1] != "auto" {
+		d, err := time.ParseDuration(cmd.args[1])
+		if err != nil || d < time.Minute || d > 10*365*24*time.Hour {
+			return fmt.Errorf("interval must be a duration of at least 1m (e.g. 30m or 6h) or 'auto', got '%s'", cmd.args[1])
+		}
+		interval = sql.NullInt32{Int32: int32(d / time.Second), Valid: true}
+		if feed.LastFetchedAt.Valid {
+			next = sql.NullTime{Time: feed.LastFetchedAt.Time.Add(d), Valid: true}
+		}
+	}
+	err = s.db.SetFeedInterval(context.Background(), database.SetFeedIntervalParams{
+		ID : feed.ID,
+		FetchInterval : interval,
+		NextFetchAt : next,
+		UpdatedAt : time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Feed '%s' is now fetched every %s\n", feed.Name, formatInterval(interval))
+	return nil
+}
+
+// handlerFeedProxy is admin only: the proxy host is trusted like the one in
+// the config, so it may be on a private network.
+func handlerFeedProxy(s *state, cmd command, user database.User) error {
+	feed, err := getManagedFeed(s, cmd.args[0], user)
+	if err != nil {
+		return err
+	}
+	var proxy sql.NullString
+	if cmd.args[1] != "default" {
+		if _, err := rss.ParseProxy(cmd.args[1]); err != nil {
+			return err
+		}
+		proxy = sql.NullString{String: cmd.args[1], Valid: true}
+	}
+	err = s.db.SetFeedProxy(context.Background(), database.SetFeedProxyParams{
+		ID : feed.ID,
+		Proxy : proxy,
+		UpdatedAt : time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case !proxy.Valid:
+		fmt.Printf("Feed '%s' now uses the fetch.proxy setting\n", feed.Name)
+	case proxy.String == rss.Direct:
+		fmt.Printf("Feed '%s' is now fetched without a proxy\n", feed.Name)
+	default:
+		fmt.Printf("Feed '%s' is now fetched through %s\n", feed.Name, proxy.String)
+	}
+	return nil
+}
+
+func formatInterval(interval sql.NullInt32) string {
+	if !interval.Valid {
+		return "auto"
+	}
+	return (time.Duration(interval.Int32) * time.Second).String()
+}
+
+func completeFeedInterval(s *state, args []string) []string {
+	if len(args) == 1 {
+		return []string{"auto"}
+	}
+	return completeFeedURLs(s, args)
+}
+
+func completeFeedProxy(s *state, args []string) []string {
+	if len(args) == 1 {
+		return []string{"default", rss.Direct}
+	}
+	return completeFeedURLs(s, args)
+}
+
+// completeFeedThenUser completes a feed URL followed by a username.
+func completeFeedThenUser(s *state, args []string) []string {
+	if len(args) == 1 {
+		return completeUsernames(s, nil)
+	}
+	return completeFeedURLs(s, args)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/andrei-himself/gator/internal/config"
+	"github.com/andrei-himself/gator/internal/database"
+	"github.com/andrei-himself/gator/internal/output"
+	"github.com/andrei-himself/gator/internal/rss"
+	"github.com/andrei-himself/gator/internal/secret"
+	"golang.org/x/net/http/httpguts"
+)
+
+// secretKey returns the key that encrypts feed credentials. With create set,
+// a missing key is generated and saved to the active profile.
+func secretKey(s *state, create bool) ([]byte, error) {
+	if s.cfg.SecretKey == "" {
+		if !create {
+			return nil, fmt.Errorf("no secret_key in the config, copy it from the machine that stored the credentials or set %s", config.EnvSecretKey)
+		}
+		key, err := secret.NewKey()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.cfg.SetSecretKey(key); err != nil {
+			return nil, err
+		}
+		fmt.Printf("Generated a secret_key in %s, copy it to other machines running agg against this database\n", s.cfg.Path())
+	}
+	return secret.ParseKey(s.cfg.SecretKey)
+}
+
+// loadCredentials returns the decrypted credentials of a feed, or nil if it
+// has none.
+func loadCredentials(s *state, feed database.Feed) (*rss.Credentials, error) {
+	stored, err := s.db.GetFeedCredentials(context.Background(), feed.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	key, err := secretKey(s, false)
+	if err != nil {
+		return nil, fmt.Errorf("feed '%s' has credentials: %w", feed.Name, err)
+	}
+	plaintext, err := secret.Open(key, stored.Encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("feed '%s' has credentials: %w", feed.Name, err)
+	}
+	var creds rss.Credentials
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil, err
+	}
+	return &creds, nil
+}
+
+// saveCredentials encrypts and stores creds, or removes them when empty.
+func saveCredentials(s *state, feed database.Feed, creds *rss.Credentials) error {
+	if creds.Empty() {
+		return s.db.DeleteFeedCredentials(context.Background(), feed.ID)
+	}
+	key, err := secretKey(s, true)
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	encrypted, err := secret.Seal(key, plaintext)
+	if err != nil {
+		return err
+	}
+	return s.db.SetFeedCredentials(context.Background(), database.SetFeedCredentialsParams{
+		FeedID : feed.ID,
+		Encrypted : encrypted,
+		CreatedAt : time.Now(),
+	})
+}
+
+// updateCredentials loads the credentials of a feed user may change, lets
+// change modify them and saves the result.
+func updateCredentials(s *state, url string, user database.User, change func(creds *rss.Credentials) error) (database.Feed, error) {
+	feed, err := getManagedFeed(s, url, user)
+	if err != nil {
+		return database.Feed{}, err
+	}
+	creds, err := loadCredentials(s, feed)
+	if err != nil {
+		return database.Feed{}, err
+	}
+	if creds == nil {
+		creds = &rss.Credentials{}
+	}
+	if err := change(creds); err != nil {
+		return database.Feed{}, err
+	}
+	return feed, saveCredentials(s, feed, creds)
+}
+
+// readSecret prompts for a value that must not be empty.
+func readSecret(prompt string) (string, error) {
+	value, err := readPassword(prompt)
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", fmt.Errorf("value can't be empty")
+	}
+	return value, nil
+}
+
+func handlerFeedAuthBasic(s *state, cmd command, user database.User) error {
+	feed, err := updateCredentials(s, cmd.args[0], user, func(creds *rss.Credentials) error {
+		password, err := readSecret("Password: ")
+		if err != nil {
+			return err
+		}
+		creds.Username, creds.Password, creds.Token = cmd.args[1], password, ""
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Feed '%s' now uses basic auth as '%s'\n", feed.Name, cmd.args[1])
+	return nil
+}
+
+func handlerFeedAuthBearer(s *state, cmd command, user database.User) error {
+	feed, err := updateCredentials(s, cmd.args[0], user, func(creds *rss.Credentials) error {
+		token, err := readSecret("Token: ")
+		if err != nil {
+			return err
+		}
+		creds.Username, creds.Password, creds.Token = "", "", token
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Feed '%s' now uses a bearer token\n", feed.Name)
+	return nil
+}
+
+func handlerFeedAuthCookie(s *state, cmd command, user database.User) error {
+	feed, err := updateCredentials(s, cmd.args[0], user, func(creds *rss.Credentials) error {
+		cookie, err := readSecret("Cookie: ")
+		if err != nil {
+			return err
+		}
+		creds.Cookie = cookie
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Feed '%s' now sends a cookie\n", feed.Name)
+	return nil
+}
+
+func handlerFeedAuthHeader(s *state, cmd command, user database.User) error {
+	name := http.CanonicalHeaderKey(cmd.args[1])
+	if !httpguts.ValidHeaderFieldName(name) {
+		return fmt.Errorf("'%s' isn't a valid header name", cmd.args[1])
+	}
+	switch name {
+	case "Host", "Authorization", "Cookie", "Content-Length", "Accept-Encoding":
+		return fmt.Errorf("%s header can't be set with 'feed auth header'", name)
+	}
+	var removed bool
+	feed, err := updateCredentials(s, cmd.args[0], user, func(creds *rss.Credentials) error {
+		value, err := readPassword(fmt.Sprintf("Value for %s (empty to remove): ", name))
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			_, ok := creds.Headers[name]
+			if !ok {
+				return fmt.Errorf("feed doesn't send a %s header", name)
+			}
+			delete(creds.Headers, name)
+			removed = true
+			return nil
+		}
+		if creds.Headers == nil {
+			creds.Headers = map[string]string{}
+		}
+		creds.Headers[name] = value
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if removed {
+		fmt.Printf("Feed '%s' no longer sends a %s header\n", feed.Name, name)
+	} else {
+		fmt.Printf("Feed '%s' now sends a %s header\n", feed.Name, name)
+	}
+	return nil
+}
+
+func handlerFeedAuthClear(s *state, cmd command, user database.User) error {
+	feed, err := getManagedFeed(s, cmd.args[0], user)
+	if err != nil {
+		return err
+	}
+	if err := s.db.DeleteFeedCredentials(context.Background(), feed.ID); err != nil {
+		return err
+	}
+	fmt.Printf("Credentials removed from feed '%s'\n", feed.Name)
+	return nil
+}
+
+// handlerFeedAuthShow lists which credentials a feed has, never their values.
+func handlerFeedAuthShow(s *state, cmd command, user database.User) error {
+	feed, err := getManagedFeed(s, cmd.args[0], user)
+	if err != nil {
+		return err
+	}
+	creds, err := loadCredentials(s, feed)
+	if err != nil {
+		return err
+	}
+	table := output.Table{
+		Columns : []output.Column{
+			{Name : "kind"},
+			{Name : "detail"},
+		},
+	}
+	if creds != nil {
+		if creds.Username != "" {
+			table.Add("basic", "username "+creds.Username)
+		}
+		if creds.Token != "" {
+			table.Add("bearer", nil)
+		}
+		if creds.Cookie != "" {
+			table.Add("cookie", nil)
+		}
+		for _, name := range creds.HeaderNames() {
+			table.Add("header", name)
+		}
+	}
+	return printTable(cmd, table)
+}
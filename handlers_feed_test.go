@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestCredentialsStay(t *testing.T) {
+	tests := []struct {
+		name   string
+		oldURL string
+		newURL string
+		want   bool
+	}{
+		{"same URL", "https://example.com/feed", "https://example.com/feed", true},
+		{"new path", "https://example.com/feed", "https://example.com/blog/feed.xml", true},
+		{"host case", "https://example.com/feed", "https://EXAMPLE.com/feed", true},
+		{"http to https", "http://example.com/feed", "https://example.com/feed", true},
+		{"https to http", "https://example.com/feed", "http://example.com/feed", false},
+		{"other host", "https://example.com/feed", "https://evil.example/feed", false},
+		{"subdomain", "https://example.com/feed", "https://feeds.example.com/feed", false},
+		{"other port", "https://example.com/feed", "https://example.com:8443/feed", false},
+		{"userinfo trick", "https://example.com/feed", "https://example.com@evil.example/feed", false},
+		{"invalid URL", "https://example.com/feed", "https://exa mple.com/%zz", false},
+	}
+	for _, tt := range tests {
+		if got := credentialsStay(tt.oldURL, tt.newURL); got != tt.want {
+			t.Errorf("%s: credentialsStay(%q, %q) = %v, want %v", tt.name, tt.oldURL, tt.newURL, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/andrei-himself/gator/internal/canonical"
+	"github.com/andrei-himself/gator/internal/database"
+	"github.com/andrei-himself/gator/internal/render"
+	"github.com/google/uuid"
+	"golang.org/x/term"
+)
+
+// maxTextWidth keeps rendered posts readable on wide terminals.
+const maxTextWidth = 100
+
+func handlerShow(s *state, cmd command, user database.User) error {
+	post, err := getPost(s, cmd.args[0], user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("post '%s' not found in the feeds you follow", cmd.args[0])
+	} else if err != nil {
+		return err
+	}
+	printPost(os.Stdout, post.Title, post.FeedNames, post.PublishedAt, post.Url, post.Description)
+	return s.db.MarkPostRead(context.Background(), database.MarkPostReadParams{
+		UserID : user.ID,
+		PostID : post.ID,
+		ReadAt : time.Now(),
+	})
+}
+
+// getPost looks up a post by its ID or URL among the posts of the feeds user
+// follows. Other posts aren't found, as if they didn't exist.
+func getPost(s *state, idOrURL string, user database.User) (database.GetPostForUserRow, error) {
+	if id, err := uuid.Parse(idOrURL); err == nil {
+		return s.db.GetPostForUser(context.Background(), database.GetPostForUserParams{
+			ID : id,
+			UserID : user.ID,
+		})
+	}
+	url, err := canonicalURL(s, idOrURL)
+	if err != nil {
+		return database.GetPostForUserRow{}, err
+	}
+	post, err := s.db.GetPostByURLsForUser(context.Background(), database.GetPostByURLsForUserParams{
+		Urls : canonical.Variants(url),
+		UserID : user.ID,
+	})
+	return database.GetPostForUserRow(post), err
+}
+
+// printPost writes a post as wrapped text, with the URLs of its links and
+// images as footnotes.
+func printPost(w io.Writer, title sql.NullString, feeds string, published time.Time, url string, description sql.NullString) {
+	width := textWidth()
+	fmt.Fprintln(w, strings.Join(render.Wrap(nullTitle(title), width, ""), "\n"))
+	fmt.Fprintln(w, feeds+" · "+published.Local().Format("Mon, 02 Jan 2006 15:04"))
+	fmt.Fprintln(w, url)
+	if description.Valid && strings.TrimSpace(description.String) != "" {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, render.HTMLToTextWithLinks(description.String, width))
+	}
+}
+
+// textWidth is the width of the terminal, or 80 columns when stdout isn't
+// one.
+func textWidth() int {
+	w, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || w <= 0 {
+		return 80
+	}
+	return min(w, maxTextWidth)
+}
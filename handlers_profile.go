@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/andrei-himself/gator/internal/output"
+)
+
+func handlerProfileList(s *state, cmd command) error {
+	table := output.Table{
+		Columns : []output.Column{
+			{Name : "name"},
+			{Name : "active"},
+			{Name : "db_url", MaxWidth : 60},
+			{Name : "current_user_name"},
+		},
+	}
+	for _, name := range s.cfg.ProfileNames() {
+		p, _ := s.cfg.Profile(name)
+		table.Add(name, name == s.cfg.ActiveProfile(), redactDBURL(p.DBURL), p.CurrentUserName)
+	}
+	return printTable(cmd, table)
+}
+
+func handlerProfileUse(s *state, cmd command) error {
+	name := cmd.args[0]
+	if err := s.cfg.UseProfile(name); err != nil {
+		return err
+	}
+	s.sessionUser = ""
+	if err := s.connect(); err != nil {
+		return err
+	}
+	fmt.Printf("Switched to profile '%s'\n", name)
+	return nil
+}
+
+func handlerProfileAdd(s *state, cmd command) error {
+	name, dbURL := cmd.args[0], cmd.args[1]
+	if err := validateDBURL(dbURL); err != nil {
+		return err
+	}
+	if err := s.cfg.AddProfile(name, dbURL); err != nil {
+		return err
+	}
+	fmt.Printf("Profile '%s' added, run 'gator profile use %s' to switch to it\n", name, name)
+	return nil
+}
+
+func handlerProfileRemove(s *state, cmd command) error {
+	name := cmd.args[0]
+	active := s.cfg.ActiveProfile()
+	if err := s.cfg.RemoveProfile(name); err != nil {
+		return err
+	}
+	if active == name {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Profile '%s' removed\n", name)
+	return nil
+}
+
+// applyProfile handles the global --profile flag.
+func applyProfile(s *state, name string) error {
+	if name == s.cfg.ActiveProfile() {
+		return nil
+	}
+	if err := s.cfg.SelectProfile(name); err != nil {
+		return err
+	}
+	s.sessionUser = ""
+	return s.connect()
+}
+
+func completeProfiles(s *state, args []string) []string {
+	if len(args) > 0 || s.cfg == nil {
+		return nil
+	}
+	return s.cfg.ProfileNames()
+}
+
+// redactDBURL hides the password in a database URL.
+func redactDBURL(dbURL string) string {
+	u, err := url.Parse(dbURL)
+	if err != nil || u.User == nil {
+		return dbURL
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andrei-himself/gator/internal/database"
+)
+
+// getUser looks up a user by name with a friendly error if it doesn't exist.
+func getUser(s *state, name string) (database.User, error) {
+	user, err := s.db.GetUser(context.Background(), name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("user '%s' doesn't exist in the database", name)
+	}
+	return user, err
+}
+
+// checkLastAdmin refuses changes that would leave the database without an
+// admin.
+func checkLastAdmin(s *state, target database.User) error {
+	if !target.IsAdmin {
+		return nil
+	}
+	admins, err := s.db.CountAdmins(context.Background())
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return fmt.Errorf("'%s' is the only admin, make another user admin first", target.Name)
+	}
+	return nil
+}
+
+func handlerUsersDelete(s *state, cmd command, user database.User) error {
+	target, err := getUser(s, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	if target.ID != user.ID && !user.IsAdmin {
+		return fmt.Errorf("only admins can delete other users")
+	}
+	if err := checkLastAdmin(s, target); err != nil {
+		return err
+	}
+	if err := s.db.DeleteUser(context.Background(), target.ID); err != nil {
+		return err
+	}
+	// posts of their feeds that no other feed has
+	if err := s.db.DeleteOrphanPosts(context.Background()); err != nil {
+		return err
+	}
+	if s.sessionUser == target.Name {
+		s.sessionUser = ""
+	}
+	if s.cfg.CurrentUserName == target.Name {
+		if err := s.cfg.SetUser(""); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("User '%s' deleted along with their feeds and follows\n", target.Name)
+	return nil
+}
+
+func handlerUsersRename(s *state, cmd command, user database.User) error {
+	oldName, newName := cmd.args[0], cmd.args[1]
+	target, err := getUser(s, oldName)
+	if err != nil {
+		return err
+	}
+	if target.ID != user.ID && !user.IsAdmin {
+		return fmt.Errorf("only admins can rename other users")
+	}
+	_, err = s.db.GetUser(context.Background(), newName)
+	if err == nil {
+		return fmt.Errorf("user '%s' already exists", newName)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	err = s.db.RenameUser(context.Background(), database.RenameUserParams{
+		ID : target.ID,
+		Name : newName,
+		UpdatedAt : time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if s.sessionUser == oldName {
+		s.sessionUser = newName
+	}
+	if s.cfg.CurrentUserName == oldName {
+		if err := s.cfg.SetSession(newName, s.cfg.SessionToken); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("User '%s' renamed to '%s'\n", oldName, newName)
+	return nil
+}
+
+func handlerUsersAdmin(s *state, cmd command, user database.User) error {
+	target, err := getUser(s, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	revoke := cmd.boolFlag("revoke")
+	if revoke {
+		if err := checkLastAdmin(s, target); err != nil {
+			return err
+		}
+	}
+	err = s.db.SetUserAdmin(context.Background(), database.SetUserAdminParams{
+		ID : target.ID,
+		IsAdmin : !revoke,
+		UpdatedAt : time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if revoke {
+		fmt.Printf("User '%s' is no longer an admin\n", target.Name)
+	} else {
+		fmt.Printf("User '%s' is now an admin\n", target.Name)
+	}
+	return nil
+}
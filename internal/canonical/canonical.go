@@ -0,0 +1,156 @@
+// Package canonical normalizes URLs so trivial variations of the same
+// address, such as an upper case host or a utm_source parameter, compare
+// equal.
+package canonical
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// DefaultTrackingParams are the query parameters removed when no list is
+// configured. A trailing * matches any parameter with that prefix.
+var DefaultTrackingParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"gbraid",
+	"wbraid",
+	"msclkid",
+	"yclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_hsenc",
+	"_hsmi",
+	"mkt_tok",
+}
+
+var defaultPorts = map[string]string{
+	"http" : "80",
+	"https" : "443",
+}
+
+// URL returns the canonical form of rawURL: lower case scheme and host, no
+// default port, no fragment and none of the tracking query parameters.
+// Other parameters keep their order and encoding. URLs that aren't http or
+// https are only trimmed.
+func URL(rawURL string, tracking []string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return rawURL, nil
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+	u.Fragment, u.RawFragment = "", ""
+	u.RawQuery = stripParams(u.RawQuery, tracking)
+	u.ForceQuery = false
+	if u.Path == "" && u.RawPath == "" {
+		u.Path = "/"
+	}
+	return u.String(), nil
+}
+
+// stripParams removes tracking parameters from a raw query string.
+func stripParams(rawQuery string, tracking []string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	var kept []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(param, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if !isTracking(strings.ToLower(key), tracking) {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+func isTracking(key string, tracking []string) bool {
+	for _, pattern := range tracking {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Variants returns canonicalURL followed by the forms that usually name the
+// same resource: the other of http and https, and the path with or without
+// a trailing slash. They are meant for lookups; URLs are stored as given,
+// since a site may only serve one of the forms.
+func Variants(canonicalURL string) []string {
+	variants := []string{canonicalURL}
+	u, err := url.Parse(canonicalURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return variants
+	}
+	schemes := []string{u.Scheme, "https"}
+	if u.Scheme == "https" {
+		schemes[1] = "http"
+	}
+	paths := []string{u.EscapedPath()}
+	if p := paths[0]; p != "/" {
+		if trimmed, ok := strings.CutSuffix(p, "/"); ok {
+			paths = append(paths, trimmed)
+		} else {
+			paths = append(paths, p+"/")
+		}
+	}
+	for _, scheme := range schemes {
+		for _, path := range paths {
+			v := *u
+			v.Scheme = scheme
+			v.Path, v.RawPath = "", ""
+			if unescaped, err := url.PathUnescape(path); err == nil {
+				v.Path = unescaped
+				v.RawPath = path
+			}
+			if s := v.String(); s != canonicalURL {
+				variants = append(variants, s)
+			}
+		}
+	}
+	return variants
+}
+
+// ParseParams splits a comma separated list of tracking parameters. An
+// empty value means DefaultTrackingParams and "none" an empty list.
+func ParseParams(value string) []string {
+	value = strings.TrimSpace(value)
+	switch value {
+	case "":
+		return DefaultTrackingParams
+	case "none":
+		return nil
+	}
+	var params []string
+	for _, param := range strings.Split(value, ",") {
+		if param = strings.TrimSpace(param); param != "" {
+			params = append(params, param)
+		}
+	}
+	return params
+}
@@ -0,0 +1,102 @@
+package canonical
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		tracking []string
+		want     string
+	}{
+		{"unchanged", "https://example.com/feed.xml", DefaultTrackingParams, "https://example.com/feed.xml"},
+		{"lower case scheme and host", "HTTPS://Example.COM/Feed.xml", DefaultTrackingParams, "https://example.com/Feed.xml"},
+		{"default http port", "http://example.com:80/feed", DefaultTrackingParams, "http://example.com/feed"},
+		{"default https port", "https://example.com:443/feed", DefaultTrackingParams, "https://example.com/feed"},
+		{"other port", "https://example.com:8443/feed", DefaultTrackingParams, "https://example.com:8443/feed"},
+		{"http port on https", "https://example.com:80/feed", DefaultTrackingParams, "https://example.com:80/feed"},
+		{"ipv6 host", "http://[::1]:80/feed", DefaultTrackingParams, "http://[::1]/feed"},
+		{"trailing dot", "https://example.com./feed", DefaultTrackingParams, "https://example.com/feed"},
+		{"fragment", "https://example.com/post#comments", DefaultTrackingParams, "https://example.com/post"},
+		{"empty path", "https://example.com", DefaultTrackingParams, "https://example.com/"},
+		{"empty query", "https://example.com/feed?", DefaultTrackingParams, "https://example.com/feed"},
+		{"surrounding space", "  https://example.com/feed \n", DefaultTrackingParams, "https://example.com/feed"},
+		{"utm params", "https://example.com/post?utm_source=rss&utm_medium=feed", DefaultTrackingParams, "https://example.com/post"},
+		{"keeps other params in order", "https://example.com/post?b=2&utm_source=rss&a=1&fbclid=x", DefaultTrackingParams, "https://example.com/post?b=2&a=1"},
+		{"keeps encoding", "https://example.com/search?q=a%20b&gclid=1", DefaultTrackingParams, "https://example.com/search?q=a%20b"},
+		{"upper case param", "https://example.com/post?UTM_Source=rss&id=7", DefaultTrackingParams, "https://example.com/post?id=7"},
+		{"encoded param name", "https://example.com/post?utm%5Fsource=rss&id=7", DefaultTrackingParams, "https://example.com/post?id=7"},
+		{"custom params", "https://example.com/post?ref=hn&utm_source=rss", []string{"ref"}, "https://example.com/post?utm_source=rss"},
+		{"no params", "https://example.com/post?utm_source=rss", nil, "https://example.com/post?utm_source=rss"},
+		{"not http", "mailto:someone@example.com?utm_source=x", DefaultTrackingParams, "mailto:someone@example.com?utm_source=x"},
+		{"no host", "/relative/path?utm_source=x", DefaultTrackingParams, "/relative/path?utm_source=x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := URL(tt.raw, tt.tracking)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("URL(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+	if _, err := URL("http://exa mple.com/%zz", nil); err == nil {
+		t.Error("invalid URL was accepted")
+	}
+}
+
+func TestVariants(t *testing.T) {
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{
+			"https://example.com/feed",
+			[]string{"https://example.com/feed", "https://example.com/feed/", "http://example.com/feed", "http://example.com/feed/"},
+		},
+		{
+			"http://example.com/blog/",
+			[]string{"http://example.com/blog/", "http://example.com/blog", "https://example.com/blog/", "https://example.com/blog"},
+		},
+		{
+			"https://example.com/",
+			[]string{"https://example.com/", "http://example.com/"},
+		},
+		{
+			"https://example.com/a%2Fb?x=1",
+			[]string{"https://example.com/a%2Fb?x=1", "https://example.com/a%2Fb/?x=1", "http://example.com/a%2Fb?x=1", "http://example.com/a%2Fb/?x=1"},
+		},
+		{
+			"tag:example.com,2024:post-1",
+			[]string{"tag:example.com,2024:post-1"},
+		},
+	}
+	for _, tt := range tests {
+		if got := Variants(tt.url); !slices.Equal(got, tt.want) {
+			t.Errorf("Variants(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", DefaultTrackingParams},
+		{"  ", DefaultTrackingParams},
+		{"none", nil},
+		{"ref", []string{"ref"}},
+		{" utm_* , ref,,fbclid ", []string{"utm_*", "ref", "fbclid"}},
+	}
+	for _, tt := range tests {
+		if got := ParseParams(tt.value); !slices.Equal(got, tt.want) {
+			t.Errorf("ParseParams(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gator", "config.json")
+	t.Setenv(EnvConfig, path)
+	t.Setenv(EnvProfile, "")
+	t.Setenv(EnvDBURL, "")
+
+	if _, err := ReadExisting(); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("ReadExisting without a file: err = %v, want os.ErrNotExist", err)
+	}
+	if _, err := os.Stat(filepath.Dir(path)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("ReadExisting created %s", filepath.Dir(path))
+	}
+
+	if _, err := Read(); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := ReadExisting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.DBURL != DefaultDBURL {
+		t.Errorf("DBURL = %q, want %q", conf.DBURL, DefaultDBURL)
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// marshalWithExtra encodes v and appends the unrecognized fields that were
+// read from the file, so keys written by newer versions or by hand survive.
+func marshalWithExtra(v any, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.Write(bytes.TrimSuffix(data, []byte("}")))
+	for i, k := range keys {
+		if i > 0 || len(data) > 2 {
+			buf.WriteString(",")
+		}
+		key, _ := json.Marshal(k)
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(extra[k])
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// unmarshalWithExtra decodes data into the struct pointed to by v and returns
+// the fields that don't match any of its json tags.
+func unmarshalWithExtra(data []byte, v any) (map[string]json.RawMessage, error) {
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(v).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		delete(all, name)
+	}
+	if len(all) == 0 {
+		return nil, nil
+	}
+	return all, nil
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fetch holds the settings of the HTTP client that fetches feeds. Unlike
+// profiles they apply to the whole machine. Empty values mean the default.
+type Fetch struct {
+	Timeout string `json:"timeout,omitempty"`
+	ConnectTimeout string `json:"connect_timeout,omitempty"`
+	MaxBodySize string `json:"max_body_size,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Allow string `json:"allow,omitempty"`
+	Proxy string `json:"proxy,omitempty"`
+	CAFile string `json:"ca_file,omitempty"`
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey string `json:"client_key,omitempty"`
+	extra map[string]json.RawMessage
+}
+
+func (f Fetch) MarshalJSON() ([]byte, error) {
+	type plain Fetch
+	return marshalWithExtra(plain(f), f.extra)
+}
+
+func (f *Fetch) UnmarshalJSON(data []byte) error {
+	type plain Fetch
+	var v plain
+	extra, err := unmarshalWithExtra(data, &v)
+	if err != nil {
+		return err
+	}
+	*f = Fetch(v)
+	f.extra = extra
+	return nil
+}
+
+// field returns the setting behind a "fetch." config key.
+func (f *Fetch) field(key string) (*string, func(string) error, bool) {
+	switch key {
+	case "fetch.timeout":
+		return &f.Timeout, checkDuration, true
+	case "fetch.connect_timeout":
+		return &f.ConnectTimeout, checkDuration, true
+	case "fetch.max_body_size":
+		return &f.MaxBodySize, checkSize, true
+	case "fetch.user_agent":
+		return &f.UserAgent, nil, true
+	case "fetch.allow":
+		return &f.Allow, checkAllowlist, true
+	case "fetch.proxy":
+		return &f.Proxy, checkProxy, true
+	case "fetch.ca_file":
+		return &f.CAFile, checkFile, true
+	case "fetch.client_cert":
+		return &f.ClientCert, checkFile, true
+	case "fetch.client_key":
+		return &f.ClientKey, checkFile, true
+	}
+	return nil, nil, false
+}
+
+func (c *Config) setFetch(key, value string) error {
+	_, check, ok := c.Fetch.field(key)
+	if !ok {
+		return fmt.Errorf("unknown config key '%s'", key)
+	}
+	if check != nil && value != "" {
+		if err := check(value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+	}
+	err := c.update(func(file *Config) error {
+		p, _, _ := file.Fetch.field(key)
+		*p = value
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	p, _, _ := c.Fetch.field(key)
+	*p = value
+	return nil
+}
+
+func checkDuration(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	return nil
+}
+
+func checkAllowlist(value string) error {
+	for _, entry := range AllowList(value) {
+		if strings.Contains(entry, "/") {
+			if _, err := netip.ParsePrefix(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// AllowList splits the fetch.allow setting into its entries.
+func AllowList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func checkProxy(value string) error {
+	if value == "direct" {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("expected an http, https, socks5 or socks5h URL, or 'direct'")
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("proxy URL has no host")
+	}
+	return nil
+}
+
+func checkFile(value string) error {
+	info, err := os.Stat(value)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", value)
+	}
+	return nil
+}
+
+func checkSize(value string) error {
+	_, err := ParseSize(value)
+	return err
+}
+
+var sizeUnits = []struct {
+	suffix string
+	bytes int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a byte count such as 512KB, 10MB or 1048576.
+func ParseSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("'%s' isn't a size like 512KB or 10MB", value)
+	}
+	if n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("size '%s' is too large", value)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,41 @@
+package config
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value : "1048576", want : 1048576},
+		{value : "512B", want : 512},
+		{value : "512KB", want : 512 << 10},
+		{value : "10MB", want : 10 << 20},
+		{value : "2GB", want : 2 << 30},
+		{value : "10mb", want : 10 << 20},
+		{value : " 10 MB ", want : 10 << 20},
+		{value : "", wantErr : true},
+		{value : "MB", wantErr : true},
+		{value : "0", wantErr : true},
+		{value : "-1KB", wantErr : true},
+		{value : "1.5MB", wantErr : true},
+		{value : "10TB", wantErr : true},
+		{value : "lots", wantErr : true},
+		{value : "99999999999GB", wantErr : true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseSize(%q) = %d, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSize(%q): %v", tt.value, err)
+		} else if got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const DefaultProfile = "default"
+
+type Profile struct {
+	DBURL string `json:"db_url"`
+	CurrentUserName string `json:"current_user_name"`
+	SessionToken string `json:"session_token,omitempty"`
+	SecretKey string `json:"secret_key,omitempty"`
+	TrackingParams string `json:"tracking_params,omitempty"`
+	extra map[string]json.RawMessage
+}
+
+func (p Profile) MarshalJSON() ([]byte, error) {
+	type plain Profile
+	return marshalWithExtra(plain(p), p.extra)
+}
+
+func (p *Profile) UnmarshalJSON(data []byte) error {
+	type plain Profile
+	var v plain
+	extra, err := unmarshalWithExtra(data, &v)
+	if err != nil {
+		return err
+	}
+	*p = Profile(v)
+	p.extra = extra
+	return nil
+}
+
+// ActiveProfile returns the name of the profile in effect.
+func (c *Config) ActiveProfile() string {
+	return c.active
+}
+
+// ProfileNames returns all profiles, default first and the rest sorted.
+func (c *Config) ProfileNames() []string {
+	names := []string{}
+	for name := range c.Profiles {
+		if name != DefaultProfile {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultProfile}, names...)
+}
+
+// Profile returns the settings saved for a profile, without env overrides.
+func (c *Config) Profile(name string) (Profile, bool) {
+	if name == "" || name == DefaultProfile {
+		return c.defaults, true
+	}
+	p, ok := c.Profiles[name]
+	return p, ok
+}
+
+// SelectProfile makes name the active profile for this process only.
+func (c *Config) SelectProfile(name string) error {
+	if name == "" {
+		name = DefaultProfile
+	}
+	p, ok := c.Profile(name)
+	if !ok {
+		return fmt.Errorf("profile '%s' doesn't exist, available: %s", name, strings.Join(c.ProfileNames(), ", "))
+	}
+	c.active = name
+	c.DBURL = p.DBURL
+	c.CurrentUserName = p.CurrentUserName
+	c.SessionToken = p.SessionToken
+	c.SecretKey = p.SecretKey
+	c.TrackingParams = p.TrackingParams
+	c.applyEnv()
+	return nil
+}
+
+// UseProfile selects a profile and saves it as the current one.
+func (c *Config) UseProfile(name string) error {
+	if err := c.SelectProfile(name); err != nil {
+		return err
+	}
+	err := c.update(func(file *Config) error {
+		if _, ok := file.profile(name); !ok {
+			return fmt.Errorf("profile '%s' doesn't exist", name)
+		}
+		file.CurrentProfile = name
+		if name == DefaultProfile {
+			file.CurrentProfile = ""
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.CurrentProfile = name
+	return nil
+}
+
+func (c *Config) AddProfile(name, dbURL string) error {
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("invalid profile name '%s'", name)
+	}
+	if _, ok := c.Profile(name); ok {
+		return fmt.Errorf("profile '%s' already exists", name)
+	}
+	p := Profile{DBURL : dbURL}
+	err := c.update(func(file *Config) error {
+		if _, ok := file.profile(name); ok {
+			return fmt.Errorf("profile '%s' already exists", name)
+		}
+		file.setProfile(name, p)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if c.Profiles == nil {
+		c.Profiles = map[string]Profile{}
+	}
+	c.Profiles[name] = p
+	return nil
+}
+
+func (c *Config) RemoveProfile(name string) error {
+	if name == DefaultProfile {
+		return fmt.Errorf("the default profile can't be removed")
+	}
+	if _, ok := c.Profile(name); !ok {
+		return fmt.Errorf("profile '%s' doesn't exist", name)
+	}
+	err := c.update(func(file *Config) error {
+		delete(file.Profiles, name)
+		if file.CurrentProfile == name {
+			file.CurrentProfile = ""
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	delete(c.Profiles, name)
+	if c.CurrentProfile == name {
+		c.CurrentProfile = ""
+	}
+	if c.active == name {
+		return c.SelectProfile(DefaultProfile)
+	}
+	return nil
+}
+
+func (c *Config) profile(name string) (Profile, bool) {
+	if name == "" || name == DefaultProfile {
+		return Profile{DBURL : c.DBURL, CurrentUserName : c.CurrentUserName, SessionToken : c.SessionToken, SecretKey : c.SecretKey, TrackingParams : c.TrackingParams, extra : c.defaults.extra}, true
+	}
+	p, ok := c.Profiles[name]
+	return p, ok
+}
+
+func (c *Config) setProfile(name string, p Profile) {
+	if name == "" || name == DefaultProfile {
+		c.DBURL = p.DBURL
+		c.CurrentUserName = p.CurrentUserName
+		c.SessionToken = p.SessionToken
+		c.SecretKey = p.SecretKey
+		c.TrackingParams = p.TrackingParams
+		return
+	}
+	if c.Profiles == nil {
+		c.Profiles = map[string]Profile{}
+	}
+	c.Profiles[name] = p
+}
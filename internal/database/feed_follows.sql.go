@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,10 +23,10 @@ WITH inserted_feed_follow AS (
         $4,
         $5
     )
-RETURNING id, created_at, updated_at, user_id, feed_id
+RETURNING id, created_at, updated_at, user_id, feed_id, folder
 )
 SELECT 
-inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.user_id, inserted_feed_follow.feed_id,
+inserted_feed_follow.id, inserted_feed_follow.created_at, inserted_feed_follow.updated_at, inserted_feed_follow.user_id, inserted_feed_follow.feed_id, inserted_feed_follow.folder,
 feeds.name AS feed_name,
 users.name AS user_name
 FROM inserted_feed_follow
@@ -47,6 +48,7 @@ type CreateFeedFollowRow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Folder    sql.NullString
 	FeedName  string
 	UserName  string
 }
@@ -66,6 +68,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.FeedID,
+		&i.Folder,
 		&i.FeedName,
 		&i.UserName,
 	)
@@ -98,8 +101,9 @@ func (q *Queries) DeleteFeedFollows(ctx context.Context) error {
 }
 
 const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
-SELECT feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id,
+SELECT feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id, feed_follows.folder,
 feeds.name AS feed_name,
+feeds.url AS feed_url,
 users.name AS user_name
 FROM feed_follows
 INNER JOIN feeds ON feeds.id = feed_follows.feed_id
@@ -113,7 +117,9 @@ type GetFeedFollowsForUserRow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Folder    sql.NullString
 	FeedName  string
+	FeedUrl   string
 	UserName  string
 }
 
@@ -132,7 +138,9 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.FeedID,
+			&i.Folder,
 			&i.FeedName,
+			&i.FeedUrl,
 			&i.UserName,
 		); err != nil {
 			return nil, err
@@ -147,3 +155,50 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 	}
 	return items, nil
 }
+
+const moveFeedFollows = `-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = $1
+WHERE feed_id = $2
+AND user_id NOT IN (
+    SELECT user_id FROM feed_follows
+    WHERE feed_id = $1
+)
+`
+
+type MoveFeedFollowsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedFollows, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
+
+const setFeedFollowFolder = `-- name: SetFeedFollowFolder :execrows
+UPDATE feed_follows
+SET folder = $3, updated_at = $4
+WHERE user_id = $1
+AND feed_id = $2
+`
+
+type SetFeedFollowFolderParams struct {
+	UserID    uuid.UUID
+	FeedID    uuid.UUID
+	Folder    sql.NullString
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetFeedFollowFolder(ctx context.Context, arg SetFeedFollowFolderParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setFeedFollowFolder,
+		arg.UserID,
+		arg.FeedID,
+		arg.Folder,
+		arg.UpdatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
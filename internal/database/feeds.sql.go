@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createFeed = `-- name: CreateFeed :one
@@ -24,7 +25,7 @@ VALUES (
     $6,
     NULL
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, paused_at, pause_reason, failure_count, fetch_interval, next_fetch_at, proxy
 `
 
 type CreateFeedParams struct {
@@ -54,10 +55,26 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.PausedAt,
+		&i.PauseReason,
+		&i.FailureCount,
+		&i.FetchInterval,
+		&i.NextFetchAt,
+		&i.Proxy,
 	)
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
 const deleteFeeds = `-- name: DeleteFeeds :exec
 DELETE FROM feeds
 `
@@ -67,8 +84,52 @@ func (q *Queries) DeleteFeeds(ctx context.Context) error {
 	return err
 }
 
+const getDueFeeds = `-- name: GetDueFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, paused_at, pause_reason, failure_count, fetch_interval, next_fetch_at, proxy FROM feeds
+WHERE paused_at IS NULL
+AND (next_fetch_at IS NULL OR next_fetch_at <= $1::timestamp)
+ORDER BY next_fetch_at NULLS FIRST, last_fetched_at NULLS FIRST
+`
+
+func (q *Queries) GetDueFeeds(ctx context.Context, now time.Time) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getDueFeeds, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.PausedAt,
+			&i.PauseReason,
+			&i.FailureCount,
+			&i.FetchInterval,
+			&i.NextFetchAt,
+			&i.Proxy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeedByURL = `-- name: GetFeedByURL :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, paused_at, pause_reason, failure_count, fetch_interval, next_fetch_at, proxy FROM feeds
 WHERE URL = $1
 `
 
@@ -83,12 +144,46 @@ func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.PausedAt,
+		&i.PauseReason,
+		&i.FailureCount,
+		&i.FetchInterval,
+		&i.NextFetchAt,
+		&i.Proxy,
+	)
+	return i, err
+}
+
+const getFeedByURLs = `-- name: GetFeedByURLs :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, paused_at, pause_reason, failure_count, fetch_interval, next_fetch_at, proxy FROM feeds
+WHERE url = ANY($1::text[])
+ORDER BY array_position($1::text[], url)
+LIMIT 1
+`
+
+func (q *Queries) GetFeedByURLs(ctx context.Context, urls []string) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByURLs, pq.Array(urls))
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.PausedAt,
+		&i.PauseReason,
+		&i.FailureCount,
+		&i.FetchInterval,
+		&i.NextFetchAt,
+		&i.Proxy,
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, paused_at, pause_reason, failure_count, fetch_interval, next_fetch_at, proxy FROM feeds
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -108,6 +203,12 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.PausedAt,
+			&i.PauseReason,
+			&i.FailureCount,
+			&i.FetchInterval,
+			&i.NextFetchAt,
+			&i.Proxy,
 		); err != nil {
 			return nil, err
 		}
@@ -123,13 +224,15 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 }
 
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
-ORDER BY last_fetched_at NULLS FIRST
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, paused_at, pause_reason, failure_count, fetch_interval, next_fetch_at, proxy FROM feeds
+WHERE paused_at IS NULL
+AND (next_fetch_at IS NULL OR next_fetch_at <= $1::timestamp)
+ORDER BY next_fetch_at NULLS FIRST, last_fetched_at NULLS FIRST
 FETCH FIRST 1 ROW ONLY
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
+func (q *Queries) GetNextFeedToFetch(ctx context.Context, now time.Time) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getNextFeedToFetch, now)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -139,6 +242,12 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.PausedAt,
+		&i.PauseReason,
+		&i.FailureCount,
+		&i.FetchInterval,
+		&i.NextFetchAt,
+		&i.Proxy,
 	)
 	return i, err
 }
@@ -159,3 +268,181 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams
 	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.ID, arg.LastFetchedAt)
 	return err
 }
+
+const pauseFeed = `-- name: PauseFeed :exec
+UPDATE feeds
+SET paused_at = $2,
+    pause_reason = $3,
+    updated_at = $2
+WHERE id = $1
+`
+
+type PauseFeedParams struct {
+	ID          uuid.UUID
+	PausedAt    sql.NullTime
+	PauseReason sql.NullString
+}
+
+func (q *Queries) PauseFeed(ctx context.Context, arg PauseFeedParams) error {
+	_, err := q.db.ExecContext(ctx, pauseFeed, arg.ID, arg.PausedAt, arg.PauseReason)
+	return err
+}
+
+const recordFeedFailure = `-- name: RecordFeedFailure :one
+UPDATE feeds
+SET failure_count = failure_count + 1
+WHERE id = $1
+RETURNING failure_count
+`
+
+func (q *Queries) RecordFeedFailure(ctx context.Context, id uuid.UUID) (int32, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedFailure, id)
+	var failure_count int32
+	err := row.Scan(&failure_count)
+	return failure_count, err
+}
+
+const renameFeed = `-- name: RenameFeed :exec
+UPDATE feeds
+SET name = $2,
+    updated_at = $3
+WHERE id = $1
+`
+
+type RenameFeedParams struct {
+	ID        uuid.UUID
+	Name      string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) RenameFeed(ctx context.Context, arg RenameFeedParams) error {
+	_, err := q.db.ExecContext(ctx, renameFeed, arg.ID, arg.Name, arg.UpdatedAt)
+	return err
+}
+
+const resetFeedFailures = `-- name: ResetFeedFailures :exec
+UPDATE feeds
+SET failure_count = 0
+WHERE id = $1
+`
+
+func (q *Queries) ResetFeedFailures(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, resetFeedFailures, id)
+	return err
+}
+
+const resumeFeed = `-- name: ResumeFeed :exec
+UPDATE feeds
+SET paused_at = NULL,
+    pause_reason = NULL,
+    failure_count = 0,
+    next_fetch_at = NULL,
+    updated_at = $2
+WHERE id = $1
+`
+
+type ResumeFeedParams struct {
+	ID        uuid.UUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) ResumeFeed(ctx context.Context, arg ResumeFeedParams) error {
+	_, err := q.db.ExecContext(ctx, resumeFeed, arg.ID, arg.UpdatedAt)
+	return err
+}
+
+const scheduleFeed = `-- name: ScheduleFeed :exec
+UPDATE feeds
+SET next_fetch_at = $2
+WHERE id = $1
+`
+
+type ScheduleFeedParams struct {
+	ID          uuid.UUID
+	NextFetchAt sql.NullTime
+}
+
+func (q *Queries) ScheduleFeed(ctx context.Context, arg ScheduleFeedParams) error {
+	_, err := q.db.ExecContext(ctx, scheduleFeed, arg.ID, arg.NextFetchAt)
+	return err
+}
+
+const setFeedInterval = `-- name: SetFeedInterval :exec
+UPDATE feeds
+SET fetch_interval = $2,
+    next_fetch_at = $3,
+    updated_at = $4
+WHERE id = $1
+`
+
+type SetFeedIntervalParams struct {
+	ID            uuid.UUID
+	FetchInterval sql.NullInt32
+	NextFetchAt   sql.NullTime
+	UpdatedAt     time.Time
+}
+
+func (q *Queries) SetFeedInterval(ctx context.Context, arg SetFeedIntervalParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedInterval,
+		arg.ID,
+		arg.FetchInterval,
+		arg.NextFetchAt,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const setFeedOwner = `-- name: SetFeedOwner :exec
+UPDATE feeds
+SET user_id = $2,
+    updated_at = $3
+WHERE id = $1
+`
+
+type SetFeedOwnerParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetFeedOwner(ctx context.Context, arg SetFeedOwnerParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedOwner, arg.ID, arg.UserID, arg.UpdatedAt)
+	return err
+}
+
+const setFeedProxy = `-- name: SetFeedProxy :exec
+UPDATE feeds
+SET proxy = $2,
+    updated_at = $3
+WHERE id = $1
+`
+
+type SetFeedProxyParams struct {
+	ID        uuid.UUID
+	Proxy     sql.NullString
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetFeedProxy(ctx context.Context, arg SetFeedProxyParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedProxy, arg.ID, arg.Proxy, arg.UpdatedAt)
+	return err
+}
+
+const setFeedURL = `-- name: SetFeedURL :exec
+UPDATE feeds
+SET url = $2,
+    next_fetch_at = NULL,
+    updated_at = $3
+WHERE id = $1
+`
+
+type SetFeedURLParams struct {
+	ID        uuid.UUID
+	Url       string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetFeedURL(ctx context.Context, arg SetFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedURL, arg.ID, arg.Url, arg.UpdatedAt)
+	return err
+}
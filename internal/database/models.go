@@ -19,6 +19,19 @@ type Feed struct {
 	Url           string
 	UserID        uuid.UUID
 	LastFetchedAt sql.NullTime
+	PausedAt      sql.NullTime
+	PauseReason   sql.NullString
+	FailureCount  int32
+	FetchInterval sql.NullInt32
+	NextFetchAt   sql.NullTime
+	Proxy         sql.NullString
+}
+
+type FeedCredential struct {
+	FeedID    uuid.UUID
+	Encrypted []byte
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 type FeedFollow struct {
@@ -27,6 +40,13 @@ type FeedFollow struct {
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	FeedID    uuid.UUID
+	Folder    sql.NullString
+}
+
+type FeedPost struct {
+	FeedID    uuid.UUID
+	PostID    uuid.UUID
+	CreatedAt time.Time
 }
 
 type Post struct {
@@ -37,12 +57,26 @@ type Post struct {
 	Url         string
 	Description sql.NullString
 	PublishedAt time.Time
-	FeedID      uuid.UUID
 }
 
-type User struct {
-	ID        uuid.UUID
+type PostRead struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+type Session struct {
+	TokenHash string
+	UserID    uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
+	ExpiresAt time.Time
+}
+
+type User struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	PasswordHash sql.NullString
+	IsAdmin      bool
 }
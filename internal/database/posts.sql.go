@@ -11,10 +11,11 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at)
 VALUES (
     $1,
     $2,
@@ -22,10 +23,10 @@ VALUES (
     $4,
     $5,
     $6,
-    $7,
-    $8
+    $7
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+ON CONFLICT (url) DO NOTHING
+RETURNING id, created_at, updated_at, title, url, description, published_at
 `
 
 type CreatePostParams struct {
@@ -36,7 +37,6 @@ type CreatePostParams struct {
 	Url         string
 	Description sql.NullString
 	PublishedAt time.Time
-	FeedID      uuid.UUID
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -48,7 +48,6 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Url,
 		arg.Description,
 		arg.PublishedAt,
-		arg.FeedID,
 	)
 	var i Post
 	err := row.Scan(
@@ -59,26 +58,216 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Url,
 		&i.Description,
 		&i.PublishedAt,
-		&i.FeedID,
 	)
 	return i, err
 }
 
+const deleteOrphanPosts = `-- name: DeleteOrphanPosts :exec
+DELETE FROM posts
+WHERE NOT EXISTS (
+    SELECT 1 FROM feed_posts
+    WHERE feed_posts.post_id = posts.id
+)
+`
+
+func (q *Queries) DeleteOrphanPosts(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteOrphanPosts)
+	return err
+}
+
+const deletePost = `-- name: DeletePost :exec
+DELETE FROM posts
+WHERE id = $1
+`
+
+func (q *Queries) DeletePost(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deletePost, id)
+	return err
+}
+
+const getPostByURLs = `-- name: GetPostByURLs :one
+SELECT id, created_at, updated_at, title, url, description, published_at FROM posts
+WHERE url = ANY($1::text[])
+ORDER BY array_position($1::text[], url)
+LIMIT 1
+`
+
+func (q *Queries) GetPostByURLs(ctx context.Context, urls []string) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostByURLs, pq.Array(urls))
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+	)
+	return i, err
+}
+
+const getPostByURLsForUser = `-- name: GetPostByURLsForUser :one
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at,
+string_agg(feeds.name, ', ' ORDER BY feeds.name)::text AS feed_names
+FROM posts
+INNER JOIN feed_posts ON feed_posts.post_id = posts.id
+INNER JOIN feeds ON feeds.id = feed_posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = feed_posts.feed_id
+WHERE posts.url = ANY($1::text[])
+AND feed_follows.user_id = $2
+GROUP BY posts.id
+ORDER BY array_position($1::text[], posts.url)
+LIMIT 1
+`
+
+type GetPostByURLsForUserParams struct {
+	Urls   []string
+	UserID uuid.UUID
+}
+
+type GetPostByURLsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       sql.NullString
+	Url         string
+	Description sql.NullString
+	PublishedAt time.Time
+	FeedNames   string
+}
+
+func (q *Queries) GetPostByURLsForUser(ctx context.Context, arg GetPostByURLsForUserParams) (GetPostByURLsForUserRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostByURLsForUser, pq.Array(arg.Urls), arg.UserID)
+	var i GetPostByURLsForUserRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedNames,
+	)
+	return i, err
+}
+
+const getPostForUser = `-- name: GetPostForUser :one
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at,
+string_agg(feeds.name, ', ' ORDER BY feeds.name)::text AS feed_names
+FROM posts
+INNER JOIN feed_posts ON feed_posts.post_id = posts.id
+INNER JOIN feeds ON feeds.id = feed_posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = feed_posts.feed_id
+WHERE posts.id = $1
+AND feed_follows.user_id = $2
+GROUP BY posts.id
+`
+
+type GetPostForUserParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+type GetPostForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       sql.NullString
+	Url         string
+	Description sql.NullString
+	PublishedAt time.Time
+	FeedNames   string
+}
+
+func (q *Queries) GetPostForUser(ctx context.Context, arg GetPostForUserParams) (GetPostForUserRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostForUser, arg.ID, arg.UserID)
+	var i GetPostForUserRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedNames,
+	)
+	return i, err
+}
+
+const getPostURLs = `-- name: GetPostURLs :many
+SELECT id, url FROM posts
+ORDER BY created_at
+`
+
+type GetPostURLsRow struct {
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) GetPostURLs(ctx context.Context) ([]GetPostURLsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostURLs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostURLsRow
+	for rows.Next() {
+		var i GetPostURLsRow
+		if err := rows.Scan(&i.ID, &i.Url); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT id, created_at, updated_at, title, url, description, published_at, feed_id FROM posts
-ORDER BY published_at
-FETCH FIRST $1 ROW ONLY
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at,
+string_agg(feeds.name, ', ' ORDER BY feeds.name)::text AS feed_names
+FROM posts
+INNER JOIN feed_posts ON feed_posts.post_id = posts.id
+INNER JOIN feeds ON feeds.id = feed_posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = feed_posts.feed_id
+WHERE feed_follows.user_id = $1
+GROUP BY posts.id
+ORDER BY posts.published_at DESC
+FETCH FIRST $2 ROWS ONLY
 `
 
-func (q *Queries) GetPostsForUser(ctx context.Context, limit int32) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, limit)
+type GetPostsForUserParams struct {
+	UserID uuid.UUID
+	Limit  int32
+}
+
+type GetPostsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       sql.NullString
+	Url         string
+	Description sql.NullString
+	PublishedAt time.Time
+	FeedNames   string
+}
+
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []GetPostsForUserRow
 	for rows.Next() {
-		var i Post
+		var i GetPostsForUserRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
@@ -87,7 +276,7 @@ func (q *Queries) GetPostsForUser(ctx context.Context, limit int32) ([]Post, err
 			&i.Url,
 			&i.Description,
 			&i.PublishedAt,
-			&i.FeedID,
+			&i.FeedNames,
 		); err != nil {
 			return nil, err
 		}
@@ -101,3 +290,140 @@ func (q *Queries) GetPostsForUser(ctx context.Context, limit int32) ([]Post, err
 	}
 	return items, nil
 }
+
+const getPostsWithReadState = `-- name: GetPostsWithReadState :many
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at,
+string_agg(feeds.name, ', ' ORDER BY feeds.name)::text AS feed_names,
+array_agg(feeds.id ORDER BY feeds.name)::uuid[] AS feed_ids,
+(post_reads.post_id IS NOT NULL)::boolean AS is_read
+FROM posts
+INNER JOIN feed_posts ON feed_posts.post_id = posts.id
+INNER JOIN feeds ON feeds.id = feed_posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = feed_posts.feed_id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id
+AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+GROUP BY posts.id, post_reads.post_id
+ORDER BY posts.published_at DESC
+FETCH FIRST $2 ROWS ONLY
+`
+
+type GetPostsWithReadStateParams struct {
+	UserID uuid.UUID
+	Limit  int32
+}
+
+type GetPostsWithReadStateRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       sql.NullString
+	Url         string
+	Description sql.NullString
+	PublishedAt time.Time
+	FeedNames   string
+	FeedIds     []uuid.UUID
+	IsRead      bool
+}
+
+func (q *Queries) GetPostsWithReadState(ctx context.Context, arg GetPostsWithReadStateParams) ([]GetPostsWithReadStateRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsWithReadState, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostsWithReadStateRow
+	for rows.Next() {
+		var i GetPostsWithReadStateRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedNames,
+			pq.Array(&i.FeedIds),
+			&i.IsRead,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markPostRead = `-- name: MarkPostRead :exec
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO NOTHING
+`
+
+type MarkPostReadParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+func (q *Queries) MarkPostRead(ctx context.Context, arg MarkPostReadParams) error {
+	_, err := q.db.ExecContext(ctx, markPostRead, arg.UserID, arg.PostID, arg.ReadAt)
+	return err
+}
+
+const markPostUnread = `-- name: MarkPostUnread :exec
+DELETE FROM post_reads
+WHERE user_id = $1
+AND post_id = $2
+`
+
+type MarkPostUnreadParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) MarkPostUnread(ctx context.Context, arg MarkPostUnreadParams) error {
+	_, err := q.db.ExecContext(ctx, markPostUnread, arg.UserID, arg.PostID)
+	return err
+}
+
+const movePostReads = `-- name: MovePostReads :exec
+INSERT INTO post_reads (user_id, post_id, read_at)
+SELECT user_id, $1, read_at
+FROM post_reads
+WHERE post_id = $2
+ON CONFLICT (user_id, post_id) DO NOTHING
+`
+
+type MovePostReadsParams struct {
+	ToPostID   uuid.UUID
+	FromPostID uuid.UUID
+}
+
+func (q *Queries) MovePostReads(ctx context.Context, arg MovePostReadsParams) error {
+	_, err := q.db.ExecContext(ctx, movePostReads, arg.ToPostID, arg.FromPostID)
+	return err
+}
+
+const setPostURL = `-- name: SetPostURL :exec
+UPDATE posts
+SET url = $2, updated_at = $3
+WHERE id = $1
+`
+
+type SetPostURLParams struct {
+	ID        uuid.UUID
+	Url       string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetPostURL(ctx context.Context, arg SetPostURLParams) error {
+	_, err := q.db.ExecContext(ctx, setPostURL, arg.ID, arg.Url, arg.UpdatedAt)
+	return err
+}
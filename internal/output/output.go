@@ -0,0 +1,257 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+	FormatTSV   = "tsv"
+)
+
+var Formats = []string{FormatTable, FormatJSON, FormatJSONL, FormatCSV, FormatTSV}
+
+// Column is a named field of a Table. Name is used as the JSON key and CSV
+// header, so it should never change once released. MaxWidth truncates the
+// value in human tables only; 0 means no limit.
+type Column struct {
+	Name     string
+	MaxWidth int
+}
+
+// Table is a list of rows to render. Row values may be strings, numbers,
+// bools, time.Time or nil for missing values.
+type Table struct {
+	Columns []Column
+	Rows    [][]any
+}
+
+func (t *Table) Add(values ...any) {
+	t.Rows = append(t.Rows, values)
+}
+
+func ValidFormat(format string) error {
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format '%s', expected one of %s", format, strings.Join(Formats, ", "))
+}
+
+func Write(w io.Writer, format string, t Table) error {
+	switch format {
+	case FormatTable, "":
+		return writeTable(w, t, time.Now())
+	case FormatJSON:
+		return writeJSON(w, t)
+	case FormatJSONL:
+		return writeJSONL(w, t)
+	case FormatCSV:
+		return writeDelimited(w, t, ',')
+	case FormatTSV:
+		return writeDelimited(w, t, '\t')
+	}
+	return ValidFormat(format)
+}
+
+func writeTable(w io.Writer, t Table, now time.Time) error {
+	if len(t.Rows) == 0 {
+		_, err := fmt.Fprintln(w, "No results.")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	headers := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		headers[i] = strings.ToUpper(strings.ReplaceAll(c.Name, "_", " "))
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, row := range t.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = humanValue(v, now)
+			if i < len(t.Columns) && t.Columns[i].MaxWidth > 0 {
+				cells[i] = Truncate(cells[i], t.Columns[i].MaxWidth)
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeJSON(w io.Writer, t Table) error {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, row := range t.Rows {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  ")
+		if err := writeObject(&buf, t.Columns, row); err != nil {
+			return err
+		}
+	}
+	if len(t.Rows) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeJSONL(w io.Writer, t Table) error {
+	var buf bytes.Buffer
+	for _, row := range t.Rows {
+		if err := writeObject(&buf, t.Columns, row); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeObject encodes a row as a JSON object keeping the column order, which
+// encoding/json doesn't do for maps.
+func writeObject(buf *bytes.Buffer, columns []Column, row []any) error {
+	buf.WriteString("{")
+	for i, c := range columns {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, _ := json.Marshal(c.Name)
+		buf.Write(key)
+		buf.WriteString(":")
+		var v any
+		if i < len(row) {
+			v = row[i]
+		}
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC().Format(time.RFC3339)
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	return nil
+}
+
+func writeDelimited(w io.Writer, t Table, comma rune) error {
+	headers := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		headers[i] = c.Name
+	}
+	records := [][]string{headers}
+	for _, row := range t.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = plainValue(v)
+		}
+		records = append(records, cells)
+	}
+	if comma == '\t' {
+		// TSV has no quoting, so tabs and newlines inside values become spaces.
+		clean := strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+		for _, cells := range records {
+			for i := range cells {
+				cells[i] = clean.Replace(cells[i])
+			}
+			if _, err := fmt.Fprintln(w, strings.Join(cells, "\t")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	if err := cw.WriteAll(records); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func plainValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
+
+func humanValue(v any, now time.Time) string {
+	switch v := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		if v == "" {
+			return "-"
+		}
+		return strings.Join(strings.Fields(v), " ")
+	case bool:
+		if v {
+			return "yes"
+		}
+		return "no"
+	case time.Time:
+		return RelativeTime(v, now)
+	}
+	return fmt.Sprint(v)
+}
+
+// RelativeTime formats t relative to now, e.g. "5m ago" or "in 2h". Times
+// more than a month away are shown as a date instead.
+func RelativeTime(t time.Time, now time.Time) string {
+	d := now.Sub(t)
+	suffix := " ago"
+	prefix := ""
+	if d < 0 {
+		d = -d
+		suffix = ""
+		prefix = "in "
+	}
+	var s string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		s = fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		s = fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		s = fmt.Sprintf("%dd", int(d.Hours()/24))
+	default:
+		return t.Local().Format("2006-01-02")
+	}
+	return prefix + s + suffix
+}
+
+// Truncate shortens s to at most max runes, ending it with "…" if it was
+// cut.
+func Truncate(s string, max int) string {
+	if max < 1 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
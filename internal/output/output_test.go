@@ -0,0 +1,191 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+func testTable(rows ...[]any) Table {
+	return Table{
+		Columns : []Column{
+			{Name : "name"},
+			{Name : "url", MaxWidth : 12},
+			{Name : "count"},
+			{Name : "active"},
+			{Name : "updated_at"},
+		},
+		Rows : rows,
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		table  Table
+		want   string
+	}{
+		{
+			name : "json keeps column order",
+			format : FormatJSON,
+			table : testTable([]any{"Blog", "https://example.com/", 3, true, testTime}),
+			want : "[\n  {\"name\":\"Blog\",\"url\":\"https://example.com/\",\"count\":3,\"active\":true,\"updated_at\":\"2024-05-01T12:30:00Z\"}\n]\n",
+		},
+		{
+			name : "json missing and nil values",
+			format : FormatJSON,
+			table : testTable([]any{"Blog", nil}),
+			want : "[\n  {\"name\":\"Blog\",\"url\":null,\"count\":null,\"active\":null,\"updated_at\":null}\n]\n",
+		},
+		{
+			name : "json escapes",
+			format : FormatJSON,
+			table : testTable([]any{"a \"quoted\"\nname <b>", "", 0, false, nil}),
+			want : "[\n  {\"name\":\"a \\\"quoted\\\"\\nname \\u003cb\\u003e\",\"url\":\"\",\"count\":0,\"active\":false,\"updated_at\":null}\n]\n",
+		},
+		{
+			name : "json empty",
+			format : FormatJSON,
+			table : testTable(),
+			want : "[]\n",
+		},
+		{
+			name : "jsonl",
+			format : FormatJSONL,
+			table : testTable([]any{"A", "a", 1, true, nil}, []any{"B", "b", 2, false, nil}),
+			want : "{\"name\":\"A\",\"url\":\"a\",\"count\":1,\"active\":true,\"updated_at\":null}\n{\"name\":\"B\",\"url\":\"b\",\"count\":2,\"active\":false,\"updated_at\":null}\n",
+		},
+		{
+			name : "jsonl empty",
+			format : FormatJSONL,
+			table : testTable(),
+			want : "",
+		},
+		{
+			name : "csv",
+			format : FormatCSV,
+			table : testTable([]any{"Blog", "https://example.com/", 3, true, testTime}),
+			want : "name,url,count,active,updated_at\nBlog,https://example.com/,3,true,2024-05-01T12:30:00Z\n",
+		},
+		{
+			name : "csv quotes commas, quotes and newlines",
+			format : FormatCSV,
+			table : testTable([]any{"Smith, John", "say \"hi\"", "two\nlines", "tab\there", nil}),
+			want : "name,url,count,active,updated_at\n\"Smith, John\",\"say \"\"hi\"\"\",\"two\nlines\",tab\there,\n",
+		},
+		{
+			name : "csv empty has a header",
+			format : FormatCSV,
+			table : testTable(),
+			want : "name,url,count,active,updated_at\n",
+		},
+		{
+			name : "tsv",
+			format : FormatTSV,
+			table : testTable([]any{"Blog", "https://example.com/", 3, true, testTime}),
+			want : "name\turl\tcount\tactive\tupdated_at\nBlog\thttps://example.com/\t3\ttrue\t2024-05-01T12:30:00Z\n",
+		},
+		{
+			name : "tsv replaces tabs and newlines",
+			format : FormatTSV,
+			table : testTable([]any{"tab\there", "two\nlines", "crlf\r\nend", "a, b", nil}),
+			want : "name\turl\tcount\tactive\tupdated_at\ntab here\ttwo lines\tcrlf end\ta, b\t\n",
+		},
+		{
+			name : "tsv empty has a header",
+			format : FormatTSV,
+			table : testTable(),
+			want : "name\turl\tcount\tactive\tupdated_at\n",
+		},
+		{
+			name : "table empty",
+			format : FormatTable,
+			table : testTable(),
+			want : "No results.\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.format, tt.table); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "xml", testTable()); err == nil {
+		t.Error("unknown format was accepted")
+	}
+	if err := ValidFormat("csv"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	now := testTime.Add(5 * time.Minute)
+	table := testTable(
+		[]any{"Blog", "https://example.com/feed.xml", 3, true, testTime},
+		[]any{"Ünïcödé   spaced\nname", "", nil, false, nil},
+	)
+	var buf bytes.Buffer
+	if err := writeTable(&buf, table, now); err != nil {
+		t.Fatal(err)
+	}
+	want := "" +
+		"NAME                 URL           COUNT  ACTIVE  UPDATED AT\n" +
+		"Blog                 https://exa…  3      yes     5m ago\n" +
+		"Ünïcödé spaced name  -             -      no      -\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"hello world", 8, "hello w…"},
+		{"héllo wörld", 8, "héllo w…"},
+		{"日本語のテキスト", 5, "日本語の…"},
+		{"emoji 🎉🎉🎉", 8, "emoji 🎉…"},
+		{"abc", 1, "…"},
+		{"abc", 0, ""},
+		{"", 3, ""},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.s, tt.max); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{testTime.Add(-30 * time.Second), "just now"},
+		{testTime.Add(-5 * time.Minute), "5m ago"},
+		{testTime.Add(3 * time.Hour), "in 3h"},
+		{testTime.Add(-49 * time.Hour), "2d ago"},
+		{time.Date(2023, 1, 2, 12, 0, 0, 0, time.Local), "2023-01-02"},
+	}
+	for _, tt := range tests {
+		if got := RelativeTime(tt.t, testTime); got != tt.want {
+			t.Errorf("RelativeTime(%s) = %q, want %q", tt.t, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true,
+	"footer": true, "blockquote": true, "pre": true, "ul": true, "ol": true,
+	"li": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true,
+	"h6": true, "table": true, "tr": true, "hr": true, "figure": true,
+	"figcaption": true, "dl": true, "dt": true, "dd": true,
+}
+
+var skipTags = map[string]bool{
+	"script": true, "style": true, "head": true, "noscript": true,
+	"iframe": true, "object": true, "embed": true, "template": true,
+}
+
+// HTMLToText converts an HTML fragment to plain text wrapped at width
+// columns. Paragraph structure and list items are kept, markup is dropped.
+// The fragment is sanitized first.
+func HTMLToText(s string, width int) string {
+	return toText(s, width, nil)
+}
+
+// HTMLToTextWithLinks is HTMLToText with a numbered marker after each link
+// and image, such as "gator[1]", and their URLs listed at the end.
+func HTMLToTextWithLinks(s string, width int) string {
+	notes := &footnotes{index : map[string]int{}}
+	text := toText(s, width, notes)
+	if len(notes.urls) == 0 {
+		return text
+	}
+	out := []string{text, ""}
+	for i, u := range notes.urls {
+		// wrap the URL next to its label, so a long one isn't moved below
+		// it, unless that leaves it less than Wrap's narrowest line
+		label := fmt.Sprintf("[%d] ", i+1)
+		urlWidth := width - len(label)
+		if urlWidth < minWidth {
+			out = append(out, strings.TrimSpace(label))
+			label, urlWidth = "", width
+		}
+		for j, line := range Wrap(u, urlWidth, "") {
+			if j > 0 {
+				label = strings.Repeat(" ", len(label))
+			}
+			out = append(out, label+line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func toText(s string, width int, notes *footnotes) string {
+	paragraphs := extract(Sanitize(s), notes)
+	var out []string
+	for i, p := range paragraphs {
+		if p.pre {
+			out = append(out, strings.Split(p.text, "\n")...)
+		} else {
+			out = append(out, Wrap(p.text, width, p.indent)...)
+		}
+		// keep list items together
+		if p.item && i+1 < len(paragraphs) && paragraphs[i+1].item {
+			continue
+		}
+		out = append(out, "")
+	}
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return strings.Join(out, "\n")
+}
+
+// footnotes numbers the URLs of links and images, once per URL.
+type footnotes struct {
+	urls  []string
+	index map[string]int
+}
+
+func (f *footnotes) add(u string) int {
+	if n, ok := f.index[u]; ok {
+		return n
+	}
+	f.urls = append(f.urls, u)
+	f.index[u] = len(f.urls)
+	return len(f.urls)
+}
+
+type paragraph struct {
+	text   string
+	indent string
+	pre    bool
+	item   bool
+}
+
+// extract splits HTML into paragraphs. If notes is set, links and images
+// get footnote markers.
+func extract(s string, notes *footnotes) []paragraph {
+	var paragraphs []paragraph
+	var cur strings.Builder
+	// where the text of each open link starts in cur
+	var links []link
+	indent := ""
+	skip := 0
+	pre := 0
+	quote := 0
+
+	flush := func() {
+		text := cur.String()
+		cur.Reset()
+		if pre == 0 {
+			text = strings.Join(strings.Fields(text), " ")
+		} else {
+			text = strings.Trim(text, "\n")
+		}
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		prefix := strings.Repeat("> ", quote)
+		paragraphs = append(paragraphs, paragraph{text: prefix + indent + text, indent: prefix + strings.Repeat(" ", utf8.RuneCountInString(indent)), pre: pre > 0, item: indent != ""})
+		indent = ""
+	}
+
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tok := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name := tok.Data
+			if skipTags[name] {
+				if tt == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			switch {
+			case name == "br":
+				if pre > 0 {
+					cur.WriteString("\n")
+				} else {
+					flush()
+				}
+			case name == "a" && notes != nil && tt == html.StartTagToken:
+				links = append(links, link{href : attr(tok, "href"), start : cur.Len()})
+			case name == "img":
+				alt := attr(tok, "alt")
+				switch {
+				case notes != nil && alt != "":
+					fmt.Fprintf(&cur, "[image: %s][%d] ", alt, notes.add(attr(tok, "src")))
+				case notes != nil:
+					fmt.Fprintf(&cur, "[image][%d] ", notes.add(attr(tok, "src")))
+				case alt != "":
+					cur.WriteString("[image: " + alt + "] ")
+				}
+			case name == "hr":
+				flush()
+				paragraphs = append(paragraphs, paragraph{text: "----"})
+			case blockTags[name]:
+				flush()
+				switch name {
+				case "li":
+					indent = "• "
+				case "pre":
+					pre++
+				case "blockquote":
+					quote++
+				}
+			}
+		case html.EndTagToken:
+			name := tok.Data
+			if skipTags[name] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			if name == "a" && len(links) > 0 {
+				l := links[len(links)-1]
+				links = links[:len(links)-1]
+				// a block inside the link may have flushed its start
+				text := cur.String()[min(l.start, cur.Len()):]
+				if l.href != "" && strings.TrimSpace(text) != l.href {
+					// put the marker right after the link text
+					all := cur.String()
+					trimmed := strings.TrimRight(all, " \t\n")
+					cur.Reset()
+					fmt.Fprintf(&cur, "%s[%d]%s", trimmed, notes.add(l.href), all[len(trimmed):])
+				}
+			}
+			if blockTags[name] {
+				flush()
+				switch name {
+				case "pre":
+					if pre > 0 {
+						pre--
+					}
+				case "blockquote":
+					if quote > 0 {
+						quote--
+					}
+				}
+			}
+		case html.TextToken:
+			if skip == 0 {
+				cur.WriteString(tok.Data)
+			}
+		}
+	}
+	flush()
+	return paragraphs
+}
+
+type link struct {
+	href  string
+	start int
+}
+
+func attr(tok html.Token, key string) string {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// minWidth is the narrowest line Wrap makes; smaller widths are raised to it.
+const minWidth = 10
+
+// Wrap breaks text into lines of at most width runes, prefixing
+// continuation lines with indent. Existing newlines are kept. An indent
+// wider than half the width, as in deeply nested quotes, is cut short.
+func Wrap(text string, width int, indent string) []string {
+	if width < minWidth {
+		width = minWidth
+	}
+	if r := []rune(indent); len(r) > width/2 {
+		indent = string(r[:width/2])
+	}
+	var lines []string
+	for _, raw := range strings.Split(text, "\n") {
+		words := strings.Fields(raw)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		line := ""
+		// no word on line yet, which holds at most the indent
+		empty := true
+		for _, w := range words {
+			for utf8.RuneCountInString(w) > width-utf8.RuneCountInString(indent) {
+				// hard-break words (usually URLs) longer than a line
+				if !empty {
+					lines = append(lines, line)
+					line, empty = indent, true
+				}
+				r := []rune(w)
+				n := width - utf8.RuneCountInString(line)
+				if n >= len(r) {
+					// fits on the first line, which has no indent
+					break
+				}
+				lines = append(lines, line+string(r[:n]))
+				line = indent
+				w = string(r[n:])
+			}
+			switch {
+			case empty:
+				line += w
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(w) <= width:
+				line += " " + w
+			default:
+				lines = append(lines, line)
+				line = indent + w
+			}
+			empty = false
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
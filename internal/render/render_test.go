@@ -0,0 +1,186 @@
+package render
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		width  int
+		indent string
+		want   []string
+	}{
+		{
+			name : "fits",
+			text : "hello world",
+			width : 20,
+			want : []string{"hello world"},
+		},
+		{
+			name : "breaks between words",
+			text : "the quick brown fox jumps",
+			width : 10,
+			want : []string{"the quick", "brown fox", "jumps"},
+		},
+		{
+			name : "indents continuation lines",
+			text : "• the quick brown fox",
+			width : 10,
+			indent : "  ",
+			want : []string{"• the", "  quick", "  brown", "  fox"},
+		},
+		{
+			name : "keeps newlines",
+			text : "one\n\ntwo",
+			width : 10,
+			want : []string{"one", "", "two"},
+		},
+		{
+			name : "hard-breaks long words",
+			text : "https://example.com/a/b",
+			width : 10,
+			want : []string{"https://ex", "ample.com/", "a/b"},
+		},
+		{
+			name : "long word on the unindented first line",
+			text : "abcdefghi",
+			width : 10,
+			indent : "  ",
+			want : []string{"abcdefghi"},
+		},
+		{
+			name : "indent as wide as the line",
+			text : "> > > > > hello world",
+			width : 10,
+			indent : "> > > > > ",
+			want : []string{"> > > > >", "> > >hello", "> > >world"},
+		},
+		{
+			name : "indent wider than the line",
+			text : "hello https://example.com/",
+			width : 10,
+			indent : strings.Repeat("> ", 12),
+			want : []string{"hello", "> > >https", "> > >://ex", "> > >ample", "> > >.com/"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Wrap(tt.text, tt.width, tt.indent)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Wrap(%q, %d, %q) = %q, want %q", tt.text, tt.width, tt.indent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLToTextNestedQuotes(t *testing.T) {
+	for _, depth := range []int{1, 5, 12, 30} {
+		html := strings.Repeat("<blockquote>", depth) + "<p>hello world, a quoted reply</p>" + strings.Repeat("</blockquote>", depth)
+		for _, width := range []int{10, 20, 80} {
+			text := HTMLToText(html, width)
+			if !strings.Contains(text, "hello") {
+				t.Errorf("depth %d, width %d: text lost: %q", depth, width, text)
+			}
+			for _, line := range strings.Split(text, "\n") {
+				if utf8.RuneCountInString(line) > max(width, 10) {
+					t.Errorf("depth %d, width %d: line too long: %q", depth, width, line)
+				}
+			}
+		}
+	}
+}
+
+func TestHTMLToTextWithLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name : "no links",
+			in : `<p>Just text.</p>`,
+			want : "Just text.",
+		},
+		{
+			name : "numbers links in order",
+			in : `<p>See <a href="https://example.com/a">the first</a> and <a href="https://example.com/b">the second</a>.</p>`,
+			want : "See the first[1] and the second[2].\n\n[1] https://example.com/a\n[2] https://example.com/b",
+		},
+		{
+			name : "reuses the number of a repeated URL",
+			in : `<p><a href="https://example.com/a">one</a>, <a href="https://example.com/b">two</a>, <a href="https://example.com/a">one again</a></p>`,
+			want : "one[1], two[2], one again[1]\n\n[1] https://example.com/a\n[2] https://example.com/b",
+		},
+		{
+			name : "skips links whose text is the URL",
+			in : `<p>Go to <a href="https://example.com/">https://example.com/</a> or <a href="https://example.com/x">x</a></p>`,
+			want : "Go to https://example.com/ or x[1]\n\n[1] https://example.com/x",
+		},
+		{
+			name : "marker after trailing space",
+			in : `<p><a href="https://example.com/a">spaced </a>out</p>`,
+			want : "spaced[1] out\n\n[1] https://example.com/a",
+		},
+		{
+			name : "images",
+			in : `<p><img src="https://example.com/cat.png" alt="a cat"><img src="https://example.com/dog.png"></p>`,
+			want : "[image: a cat][1] [image][2]\n\n[1] https://example.com/cat.png\n[2] https://example.com/dog.png",
+		},
+		{
+			name : "links and images share numbers",
+			in : `<p><a href="https://example.com/a">a</a> <img src="https://example.com/a" alt="same"></p>`,
+			want : "a[1] [image: same][1]\n\n[1] https://example.com/a",
+		},
+		{
+			name : "unsafe links get no footnote",
+			in : `<p><a href="javascript:alert(1)">bad</a> and <a href="https://example.com/">good</a></p>`,
+			want : "bad and good[1]\n\n[1] https://example.com/",
+		},
+		{
+			name : "links across paragraphs",
+			in : `<p><a href="https://example.com/a">first</a></p><ul><li><a href="https://example.com/b">item</a></li></ul>`,
+			want : "first[1]\n\n• item[2]\n\n[1] https://example.com/a\n[2] https://example.com/b",
+		},
+		{
+			name : "wraps long footnote URLs",
+			in : `<p><a href="https://example.com/a/very/long/path/to/a/post">post</a></p>`,
+			want : "post[1]\n\n[1] https://example.com/a/very/long/path\n    /to/a/post",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTMLToTextWithLinks(tt.in, 40); got != tt.want {
+				t.Errorf("HTMLToTextWithLinks(%q)\n got: %q\nwant: %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLToTextWithLinksNarrow(t *testing.T) {
+	// twelve links, so two digit labels are covered too
+	html := `<p><a href="https://example.com/a/long/path">a</a>`
+	for i := range 11 {
+		html += fmt.Sprintf(` <a href="https://example.com/%d">%d</a>`, i, i)
+	}
+	html += `</p>`
+	for width := 1; width <= 20; width++ {
+		text := HTMLToTextWithLinks(html, width)
+		for _, line := range strings.Split(text, "\n") {
+			if utf8.RuneCountInString(line) > max(width, minWidth) {
+				t.Errorf("width %d: line too long: %q", width, line)
+			}
+		}
+	}
+
+	got := HTMLToTextWithLinks(`<p><a href="https://example.com/a/long">a</a></p>`, 12)
+	want := "a[1]\n\n[1]\nhttps://exam\nple.com/a/lo\nng"
+	if got != want {
+		t.Errorf("width 12\n got: %q\nwant: %q", got, want)
+	}
+}
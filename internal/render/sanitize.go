@@ -0,0 +1,140 @@
+package render
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedTags maps the tags kept by Sanitize to the attributes they may
+// keep. Other tags are removed but their text stays.
+var allowedTags = map[string][]string{
+	"a" : {"href", "title"},
+	"abbr" : {"title"},
+	"b" : nil, "i" : nil, "em" : nil, "strong" : nil, "u" : nil, "s" : nil,
+	"small" : nil, "sub" : nil, "sup" : nil, "mark" : nil, "del" : nil, "ins" : nil,
+	"code" : nil, "kbd" : nil, "samp" : nil, "var" : nil, "q" : nil, "cite" : nil,
+	"p" : nil, "br" : nil, "hr" : nil, "div" : nil, "span" : nil,
+	"blockquote" : {"cite"},
+	"pre" : nil,
+	"h1" : nil, "h2" : nil, "h3" : nil, "h4" : nil, "h5" : nil, "h6" : nil,
+	"ul" : nil, "ol" : {"start"}, "li" : nil, "dl" : nil, "dt" : nil, "dd" : nil,
+	"figure" : nil, "figcaption" : nil,
+	"img" : {"src", "alt", "title", "width", "height"},
+	"table" : nil, "thead" : nil, "tbody" : nil, "tfoot" : nil, "tr" : nil,
+	"th" : {"colspan", "rowspan"}, "td" : {"colspan", "rowspan"}, "caption" : nil,
+}
+
+// droppedTags are removed together with everything inside them.
+var droppedTags = map[string]bool{
+	"script" : true, "style" : true, "head" : true, "title" : true,
+	"noscript" : true, "template" : true, "iframe" : true, "frame" : true,
+	"frameset" : true, "object" : true, "embed" : true, "applet" : true,
+	"form" : true, "input" : true, "button" : true, "select" : true,
+	"textarea" : true, "svg" : true, "math" : true, "canvas" : true,
+	"audio" : true, "video" : true, "link" : true, "meta" : true, "base" : true,
+}
+
+var voidTags = map[string]bool{"br" : true, "hr" : true, "img" : true}
+
+var urlSchemes = map[string]bool{"http" : true, "https" : true, "mailto" : true}
+
+// Sanitize returns the HTML fragment with only allowlisted tags and
+// attributes. Scripts, styles, embedded content, tracking pixels and links
+// to anything but http, https and mailto URLs are removed.
+func Sanitize(fragment string) string {
+	body := &html.Node{Type : html.ElementNode, Data : "body", DataAtom : atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), body)
+	if err != nil {
+		return html.EscapeString(fragment)
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		sanitizeNode(&b, n)
+	}
+	return b.String()
+}
+
+func sanitizeNode(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		// comments and doctypes
+		return
+	}
+	if droppedTags[n.Data] || isTrackingPixel(n) {
+		return
+	}
+	attrs, allowed := allowedTags[n.Data]
+	if allowed {
+		if n.Data == "img" && safeURL(attrValue(n, "src")) == "" {
+			return
+		}
+		b.WriteString("<" + n.Data)
+		for _, a := range n.Attr {
+			if a.Namespace != "" || !slices.Contains(attrs, a.Key) {
+				continue
+			}
+			val := a.Val
+			if a.Key == "href" || a.Key == "src" || a.Key == "cite" {
+				if val = safeURL(val); val == "" {
+					continue
+				}
+			}
+			b.WriteString(" " + a.Key + `="` + html.EscapeString(val) + `"`)
+		}
+		b.WriteString(">")
+		if voidTags[n.Data] {
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sanitizeNode(b, c)
+	}
+	if allowed {
+		b.WriteString("</" + n.Data + ">")
+	}
+}
+
+// isTrackingPixel reports whether n is an image too small or too hidden to
+// be meant for reading.
+func isTrackingPixel(n *html.Node) bool {
+	if n.Data != "img" {
+		return false
+	}
+	for _, key := range []string{"width", "height"} {
+		if v := strings.TrimSpace(attrValue(n, key)); v == "0" || v == "1" || v == "1px" || v == "0px" {
+			return true
+		}
+	}
+	style := strings.ReplaceAll(strings.ToLower(attrValue(n, "style")), " ", "")
+	return strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+// safeURL returns u if it is relative or uses an allowed scheme, else "".
+func safeURL(u string) string {
+	u = strings.TrimSpace(u)
+	parsed, err := url.Parse(u)
+	if err != nil || u == "" {
+		return ""
+	}
+	if parsed.Scheme != "" && !urlSchemes[strings.ToLower(parsed.Scheme)] {
+		return ""
+	}
+	return u
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
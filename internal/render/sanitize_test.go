@@ -0,0 +1,127 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name : "keeps allowed tags",
+			in : `<p>Some <b>bold</b>, <em>emphasis</em> and <code>code</code>.</p><ul><li>one</li><li>two</li></ul>`,
+			want : `<p>Some <b>bold</b>, <em>emphasis</em> and <code>code</code>.</p><ul><li>one</li><li>two</li></ul>`,
+		},
+		{
+			name : "keeps allowed attributes",
+			in : `<a href="https://example.com/" title="Example" target="_blank" rel="x">link</a><ol start="3"><li>three</li></ol>`,
+			want : `<a href="https://example.com/" title="Example">link</a><ol start="3"><li>three</li></ol>`,
+		},
+		{
+			name : "keeps images",
+			in : `<img src="https://example.com/cat.png" alt="a cat" width="300" class="big">`,
+			want : `<img src="https://example.com/cat.png" alt="a cat" width="300">`,
+		},
+		{
+			name : "keeps relative and mailto links",
+			in : `<a href="/about">about</a> <a href="mailto:me@example.com">mail</a>`,
+			want : `<a href="/about">about</a> <a href="mailto:me@example.com">mail</a>`,
+		},
+		{
+			name : "strips script",
+			in : `<p>before</p><script>alert(document.cookie)</script><p>after</p>`,
+			want : `<p>before</p><p>after</p>`,
+		},
+		{
+			name : "strips style",
+			in : `<style>body { display: none }</style><p style="color: red">text</p>`,
+			want : `<p>text</p>`,
+		},
+		{
+			name : "strips event handlers",
+			in : `<p onclick="alert(1)">click</p><img src="https://example.com/a.png" onerror="alert(2)" onload="alert(3)">`,
+			want : `<p>click</p><img src="https://example.com/a.png">`,
+		},
+		{
+			name : "strips javascript hrefs",
+			in : `<a href="javascript:alert(1)">one</a><a href=" JaVaScRiPt:alert(2)">two</a><a href="java&#x09;script:alert(3)">three</a><a href="javascript&#58;alert(4)">four</a>`,
+			want : `<a>one</a><a>two</a><a>three</a><a>four</a>`,
+		},
+		{
+			name : "strips data hrefs and images",
+			in : `<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">data</a><img src="data:image/svg+xml,<svg onload=alert(1)>" alt="x">`,
+			want : `<a>data</a>`,
+		},
+		{
+			name : "strips other schemes",
+			in : `<a href="vbscript:msgbox(1)">vb</a><a href="file:///etc/passwd">file</a><blockquote cite="javascript:alert(1)">quote</blockquote>`,
+			want : `<a>vb</a><a>file</a><blockquote>quote</blockquote>`,
+		},
+		{
+			name : "strips embedded content with its contents",
+			in : `<iframe src="https://evil.example/"><p>fallback</p></iframe><object data="x.swf">obj</object><form action="/x"><input name="q"><button>Go</button></form><svg><script>alert(1)</script></svg>ok`,
+			want : `ok`,
+		},
+		{
+			name : "strips unknown tags but keeps their text",
+			in : `<section><custom-tag>inner</custom-tag> <font color="red">red</font></section>`,
+			want : `inner red`,
+		},
+		{
+			name : "strips tracking pixels",
+			in : `<p>text</p><img src="https://t.example/p.gif" width="1" height="1"><img src="https://t.example/q.gif" style="display: none"><img src="https://t.example/r.gif" height="0px">`,
+			want : `<p>text</p>`,
+		},
+		{
+			name : "strips comments",
+			in : `a<!-- <script>alert(1)</script> -->b`,
+			want : `ab`,
+		},
+		{
+			name : "escapes text and attributes",
+			in : `<p>1 &lt; 2 &amp; "quotes"</p><a href="https://example.com/?a=1&amp;b=&quot;2&quot;">q</a>`,
+			want : `<p>1 &lt; 2 &amp; &#34;quotes&#34;</p><a href="https://example.com/?a=1&amp;b=&#34;2&#34;">q</a>`,
+		},
+		{
+			name : "closes unclosed tags",
+			in : `<p><b>bold <i>both`,
+			want : `<p><b>bold <i>both</i></b></p>`,
+		},
+		{
+			name : "attribute breakout",
+			in : `<img src="x" alt="a" title='" onerror="alert(1)'>`,
+			want : `<img src="x" alt="a" title="&#34; onerror=&#34;alert(1)">`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.in); got != tt.want {
+				t.Errorf("Sanitize(%q)\n got: %s\nwant: %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeLeavesNoActiveContent(t *testing.T) {
+	inputs := []string{
+		`<scr<script>ipt>alert(1)</script>`,
+		`<<script>script>alert(1)<</script>/script>`,
+		`<a href="javascript:alert(1)" onmouseover="alert(2)">x</a>`,
+		`<svg/onload=alert(1)>`,
+		`<math><mtext><table><mglyph><style><img src=x onerror=alert(1)>`,
+		`<noscript><p title="</noscript><img src=x onerror=alert(1)>">`,
+		`<img src="x" onerror="alert(1)"//>`,
+	}
+	for _, in := range inputs {
+		out := strings.ToLower(Sanitize(in))
+		for _, bad := range []string{"<script", "<svg", "<style", "onerror=", "onload=", "onmouseover=", "javascript:"} {
+			if strings.Contains(out, bad) {
+				t.Errorf("Sanitize(%q) = %q, contains %s", in, out, bad)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+var boms = [][]byte{
+	{0xEF, 0xBB, 0xBF},
+	{0xFE, 0xFF},
+	{0xFF, 0xFE},
+}
+
+// newDecoder returns an XML decoder that reads body as UTF-8. The encoding
+// comes from, in order of precedence, a byte order mark, the charset of the
+// Content-Type header and the XML declaration.
+func newDecoder(body []byte, contentType string) (*xml.Decoder, error) {
+	var r io.Reader = bytes.NewReader(body)
+	converted := false
+	if hasBOM(body) {
+		r = transform.NewReader(r, unicode.BOMOverride(encoding.Nop.NewDecoder()))
+		converted = true
+	} else if enc, _ := charset.Lookup(contentTypeCharset(contentType)); enc != nil {
+		// an unknown charset in the header falls back to the declaration
+		r = enc.NewDecoder().Reader(r)
+		converted = true
+	}
+
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		if converted {
+			// the declaration describes the bytes before conversion
+			return input, nil
+		}
+		enc, _ := charset.Lookup(label)
+		if enc == nil {
+			return nil, fmt.Errorf("unsupported encoding '%s' in XML declaration", label)
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+	return decoder, nil
+}
+
+func hasBOM(body []byte) bool {
+	for _, bom := range boms {
+		if bytes.HasPrefix(body, bom) {
+			return true
+		}
+	}
+	return false
+}
+
+func contentTypeCharset(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return params["charset"]
+}
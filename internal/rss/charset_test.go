@@ -0,0 +1,114 @@
+package rss
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+func utf16Body(t *testing.T, endianness unicode.Endianness, doc string) []byte {
+	t.Helper()
+	body, err := unicode.UTF16(endianness, unicode.UseBOM).NewEncoder().String(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []byte(body)
+}
+
+func TestNewDecoder(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name : "utf-8 without declaration",
+			body : []byte("<rss><channel><title>café</title></channel></rss>"),
+			want : "café",
+		},
+		{
+			name : "iso-8859-1 declaration",
+			body : []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><rss><channel><title>caf\xe9</title></channel></rss>"),
+			want : "café",
+		},
+		{
+			name : "windows-1252 declaration",
+			body : []byte("<?xml version=\"1.0\" encoding=\"windows-1252\"?><rss><channel><title>\x93caf\xe9\x94 \x80 5</title></channel></rss>"),
+			want : "“café” € 5",
+		},
+		{
+			name : "windows-1252 content type",
+			body : []byte("<rss><channel><title>\x93caf\xe9\x94</title></channel></rss>"),
+			contentType : "application/rss+xml; charset=windows-1252",
+			want : "“café”",
+		},
+		{
+			name : "content type wins over declaration",
+			body : []byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?><rss><channel><title>caf\xe9</title></channel></rss>"),
+			contentType : "text/xml; charset=iso-8859-1",
+			want : "café",
+		},
+		{
+			name : "unknown content type charset falls back to declaration",
+			body : []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><rss><channel><title>caf\xe9</title></channel></rss>"),
+			contentType : "text/xml; charset=x-nonsense",
+			want : "café",
+		},
+		{
+			name : "content type without charset uses declaration",
+			body : []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><rss><channel><title>caf\xe9</title></channel></rss>"),
+			contentType : "application/xml",
+			want : "café",
+		},
+		{
+			name : "utf-16le with bom",
+			body : utf16Body(t, unicode.LittleEndian, "<?xml version=\"1.0\" encoding=\"UTF-16\"?><rss><channel><title>café 日本</title></channel></rss>"),
+			want : "café 日本",
+		},
+		{
+			name : "utf-16be with bom",
+			body : utf16Body(t, unicode.BigEndian, "<rss><channel><title>café 日本</title></channel></rss>"),
+			want : "café 日本",
+		},
+		{
+			name : "bom wins over content type",
+			body : utf16Body(t, unicode.LittleEndian, "<rss><channel><title>café</title></channel></rss>"),
+			contentType : "text/xml; charset=iso-8859-1",
+			want : "café",
+		},
+		{
+			name : "utf-8 bom wins over declaration",
+			body : []byte("\xef\xbb\xbf<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><rss><channel><title>café</title></channel></rss>"),
+			want : "café",
+		},
+		{
+			name : "unsupported declaration",
+			body : []byte("<?xml version=\"1.0\" encoding=\"x-nonsense\"?><rss><channel><title>cafe</title></channel></rss>"),
+			wantErr : true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder, err := newDecoder(tt.body, tt.contentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var feed RSSFeed
+			err = decoder.Decode(&feed)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got title %q, want an error", feed.Channel.Title)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if feed.Channel.Title != tt.want {
+				t.Errorf("title = %q, want %q", feed.Channel.Title, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package rss
+
+import (
+	"bufio"
+	"context"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Options configures the HTTP client shared by every FetchFeed call.
+type Options struct {
+	// Timeout bounds a whole request, including reading the body.
+	Timeout time.Duration
+	// ConnectTimeout bounds dialing and the TLS handshake.
+	ConnectTimeout time.Duration
+	// MaxBodySize is the largest feed accepted, after decompression.
+	MaxBodySize int64
+	UserAgent string
+	// Allow lists hostnames, IP addresses and CIDR ranges that may be
+	// fetched even though they are private or reserved.
+	Allow []string
+	// Proxy is the URL of an http, https or socks5 proxy, "direct" for no
+	// proxy, or empty to use HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+	Proxy string
+	// CAFile is a PEM bundle of certificate authorities trusted in addition
+	// to the system ones.
+	CAFile string
+	// ClientCert and ClientKey are PEM files of a client certificate for
+	// servers that ask for one.
+	ClientCert string
+	ClientKey string
+}
+
+var DefaultOptions = Options{
+	Timeout : 30 * time.Second,
+	ConnectTimeout : 10 * time.Second,
+	MaxBodySize : 10 << 20,
+	UserAgent : "gator (+https://github.com/andrei-himself/gator)",
+}
+
+var (
+	clientMu sync.Mutex
+	fetchPolicy, _ = newPolicy(nil)
+	client, _ = newClient(DefaultOptions, fetchPolicy)
+	options = DefaultOptions
+)
+
+// Configure replaces the shared client. Zero fields keep their defaults.
+func Configure(opts Options) error {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultOptions.Timeout
+	}
+	if opts.ConnectTimeout <= 0 {
+		opts.ConnectTimeout = DefaultOptions.ConnectTimeout
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = DefaultOptions.MaxBodySize
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultOptions.UserAgent
+	}
+	p, err := newPolicy(opts.Allow)
+	if err != nil {
+		return err
+	}
+	c, err := newClient(opts, p)
+	if err != nil {
+		return err
+	}
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	client.CloseIdleConnections()
+	client, options, fetchPolicy = c, opts, p
+	return nil
+}
+
+func currentClient() (*http.Client, Options, *policy) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	return client, options, fetchPolicy
+}
+
+// CheckURL reports whether a feed URL may be fetched under the current
+// policy, so bad URLs can be rejected before they are stored.
+func CheckURL(ctx context.Context, feedURL string) error {
+	_, _, p := currentClient()
+	return p.checkURL(ctx, feedURL)
+}
+
+func newClient(opts Options, p *policy) (*http.Client, error) {
+	dialer := net.Dialer{
+		Timeout : opts.ConnectTimeout,
+		KeepAlive : 30 * time.Second,
+	}
+	proxy, err := globalProxy(opts.Proxy, p)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		Proxy : func(req *http.Request) (*url.URL, error) {
+			if fp, ok := req.Context().Value(feedProxyKey{}).(feedProxy); ok {
+				return fp.url, nil
+			}
+			return proxy(req)
+		},
+		DialContext : p.dialContext(dialer),
+		TLSClientConfig : tlsConfig,
+		TLSHandshakeTimeout : opts.ConnectTimeout,
+		ForceAttemptHTTP2 : true,
+		MaxIdleConns : 100,
+		MaxIdleConnsPerHost : 4,
+		IdleConnTimeout : 90 * time.Second,
+		// bodies are decoded by readBody, which also knows brotli
+		DisableCompression : true,
+	}
+	return &http.Client{
+		Transport : &limitedTransport{Transport : transport, limiter : DefaultLimiter},
+		Timeout : opts.Timeout,
+		CheckRedirect : func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			stripCredentials(req, via)
+			return p.checkRequest(req, usesProxy(transport, req))
+		},
+	}, nil
+}
+
+// newTLSConfig returns the TLS settings for a custom CA bundle and client
+// certificate, or nil to use the defaults.
+func newTLSConfig(opts Options) (*tls.Config, error) {
+	if opts.CAFile == "" && opts.ClientCert == "" && opts.ClientKey == "" {
+		return nil, nil
+	}
+	cfg := &tls.Config{}
+	if opts.CAFile != "" {
+		bundle, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", opts.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		if opts.ClientCert == "" || opts.ClientKey == "" {
+			return nil, fmt.Errorf("client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+const acceptEncoding = "gzip, deflate, br"
+
+// readBody decodes the response body according to its Content-Encoding and
+// fails if the decoded body is larger than maxSize.
+func readBody(res *http.Response, maxSize int64) ([]byte, error) {
+	var r io.Reader = res.Body
+	switch strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	case "deflate":
+		r = newDeflateReader(r)
+	case "br":
+		r = brotli.NewReader(r)
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding '%s'", res.Header.Get("Content-Encoding"))
+	}
+	body, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("feed is larger than the limit of %d bytes", maxSize)
+	}
+	return body, nil
+}
+
+// newDeflateReader reads "deflate" bodies, which should be zlib streams but
+// are raw deflate data on some servers.
+func newDeflateReader(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		if zr, err := zlib.NewReader(br); err == nil {
+			return zr
+		}
+	}
+	return flate.NewReader(br)
+}
@@ -0,0 +1,67 @@
+package rss
+
+import (
+	"context"
+	"net/http"
+	"sort"
+)
+
+// Credentials are sent with every request for one feed.
+type Credentials struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token string `json:"token,omitempty"`
+	Cookie string `json:"cookie,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type credentialsKey struct{}
+
+// Empty reports whether nothing is set.
+func (c *Credentials) Empty() bool {
+	return c == nil || (c.Username == "" && c.Token == "" && c.Cookie == "" && len(c.Headers) == 0)
+}
+
+// HeaderNames returns the names of the custom headers, sorted.
+func (c *Credentials) HeaderNames() []string {
+	var names []string
+	for name := range c.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *Credentials) apply(req *http.Request) *http.Request {
+	if c.Empty() {
+		return req
+	}
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.Cookie != "" {
+		req.Header.Set("Cookie", c.Cookie)
+	}
+	return req.WithContext(context.WithValue(req.Context(), credentialsKey{}, c))
+}
+
+// stripCredentials removes credentials from a redirect that leaves the host
+// they were meant for. net/http already drops Authorization and Cookie in
+// most such cases but would forward custom headers.
+func stripCredentials(req *http.Request, via []*http.Request) {
+	c, _ := req.Context().Value(credentialsKey{}).(*Credentials)
+	if c == nil || req.URL.Host == via[0].URL.Host {
+		return
+	}
+	for name := range c.Headers {
+		req.Header.Del(name)
+	}
+	req.Header.Del("Authorization")
+	req.Header.Del("Cookie")
+}
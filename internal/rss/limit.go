@@ -0,0 +1,200 @@
+package rss
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultLimiter is shared by every FetchFeed call, so all scrapers in a
+// process respect the same per-host limits.
+var DefaultLimiter = NewHostLimiter(1, 3, 2)
+
+// sweepInterval is how often hosts nobody is waiting for are dropped.
+const sweepInterval = time.Minute
+
+// HostLimiter spaces out requests to each host with a token bucket and caps
+// how many requests to one host run at once. It is safe for concurrent use.
+type HostLimiter struct {
+	rate float64
+	burst float64
+	maxConcurrent int
+	now func() time.Time
+	mu sync.Mutex
+	hosts map[string]*hostState
+	swept time.Time
+}
+
+type hostState struct {
+	tokens float64
+	last time.Time
+	blockedUntil time.Time
+	running chan struct{}
+	// users counts the Acquire calls waiting for or holding a slot, so the
+	// host isn't dropped from under them
+	users int
+}
+
+// HostBlockedError is returned by Acquire while a host has asked us to back
+// off, e.g. with Retry-After.
+type HostBlockedError struct {
+	Host string
+	Until time.Time
+}
+
+func (e *HostBlockedError) Error() string {
+	return fmt.Sprintf("%s asked to wait until %s", e.Host, e.Until.Format(time.RFC3339))
+}
+
+// NewHostLimiter allows rate requests per second to each host, with bursts
+// of up to burst requests and at most maxConcurrent in flight.
+func NewHostLimiter(rate float64, burst, maxConcurrent int) *HostLimiter {
+	return &HostLimiter{
+		rate : rate,
+		burst : float64(burst),
+		maxConcurrent : maxConcurrent,
+		now : time.Now,
+		hosts : map[string]*hostState{},
+	}
+}
+
+// Acquire waits until a request to host may start and returns a function
+// that must be called when it is done. It fails straight away with a
+// *HostBlockedError instead of waiting out a Block.
+func (l *HostLimiter) Acquire(ctx context.Context, host string) (func(), error) {
+	l.mu.Lock()
+	h := l.lookup(host)
+	h.users++
+	l.mu.Unlock()
+	done := func() {
+		l.mu.Lock()
+		h.users--
+		l.mu.Unlock()
+	}
+
+	select {
+	case h.running <- struct{}{}:
+	case <-ctx.Done():
+		done()
+		return nil, ctx.Err()
+	}
+	release := func() {
+		<-h.running
+		done()
+	}
+	for {
+		wait, err := l.reserve(host, h)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		if wait <= 0 {
+			return release, nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			release()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Block stops requests to host until the given time.
+func (l *HostLimiter) Block(host string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h := l.lookup(host)
+	if until.After(h.blockedUntil) {
+		h.blockedUntil = until
+	}
+}
+
+func (l *HostLimiter) host(host string) *hostState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lookup(host)
+}
+
+// lookup returns the state of host, adding it if it is new. Once in a while
+// it drops the hosts that are back to their defaults: nobody is using them,
+// their bucket is full and they aren't blocked. l.mu must be held.
+func (l *HostLimiter) lookup(host string) *hostState {
+	now := l.now()
+	if now.Sub(l.swept) >= sweepInterval {
+		l.swept = now
+		for name, h := range l.hosts {
+			idle := h.users == 0 && !now.Before(h.blockedUntil)
+			if idle && h.tokens+now.Sub(h.last).Seconds()*l.rate >= l.burst {
+				delete(l.hosts, name)
+			}
+		}
+	}
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostState{
+			tokens : l.burst,
+			last : now,
+			running : make(chan struct{}, l.maxConcurrent),
+		}
+		l.hosts[host] = h
+	}
+	return h
+}
+
+// reserve takes a token for host if one is available, otherwise it returns
+// how long until there will be one.
+func (l *HostLimiter) reserve(host string, h *hostState) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Before(h.blockedUntil) {
+		return 0, &HostBlockedError{Host : host, Until : h.blockedUntil}
+	}
+	h.tokens = min(l.burst, h.tokens+now.Sub(h.last).Seconds()*l.rate)
+	h.last = now
+	if h.tokens >= 1 {
+		h.tokens--
+		return 0, nil
+	}
+	return time.Duration((1 - h.tokens) / l.rate * float64(time.Second)), nil
+}
+
+// limitedTransport acquires the limiter for every request it sends, so each
+// hop of a redirect is limited by its own host. The slot is held until the
+// response body is closed.
+type limitedTransport struct {
+	*http.Transport
+	limiter *HostLimiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.limiter.Acquire(req.Context(), req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	res, err := t.Transport.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	res.Body = &releaseBody{ReadCloser : res.Body, release : release}
+	return res, nil
+}
+
+type releaseBody struct {
+	io.ReadCloser
+	once sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,226 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when the test says so, so waits can
+// be checked without depending on how fast the test runs.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestLimiter(rate float64, burst, maxConcurrent int) (*HostLimiter, *fakeClock) {
+	clock := &fakeClock{t : time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	l := NewHostLimiter(rate, burst, maxConcurrent)
+	l.now = clock.now
+	return l, clock
+}
+
+// acquireNow is Acquire for requests that must not wait: the clock doesn't
+// move, so one that waits for a token fails instead of hanging.
+func acquireNow(l *HostLimiter, host string) (func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return l.Acquire(ctx, host)
+}
+
+func TestHostLimiterBurst(t *testing.T) {
+	l, clock := newTestLimiter(10, 3, 5)
+	h := l.host("example.com")
+	for i := 0; i < 3; i++ {
+		if wait, err := l.reserve("example.com", h); err != nil || wait != 0 {
+			t.Fatalf("request %d: wait %s, err %v, want no wait", i+1, wait, err)
+		}
+	}
+	if wait, _ := l.reserve("example.com", h); wait != 100*time.Millisecond {
+		t.Errorf("4th request waits %s, want 100ms", wait)
+	}
+	clock.advance(100 * time.Millisecond)
+	if wait, _ := l.reserve("example.com", h); wait != 0 {
+		t.Errorf("after 100ms: wait %s, want a token", wait)
+	}
+}
+
+func TestHostLimiterHostsAreIndependent(t *testing.T) {
+	l, _ := newTestLimiter(1, 1, 1)
+	for _, host := range []string{"a.example", "b.example", "c.example"} {
+		release, err := acquireNow(l, host)
+		if err != nil {
+			t.Fatalf("%s: %v", host, err)
+		}
+		release()
+	}
+}
+
+func TestHostLimiterConcurrency(t *testing.T) {
+	l, _ := newTestLimiter(1000, 10, 1)
+	release, err := acquireNow(l, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, "example.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second concurrent Acquire: got %v, want deadline exceeded", err)
+	}
+
+	release()
+	release, err = acquireNow(l, "example.com")
+	if err != nil {
+		t.Fatalf("Acquire after release: %v", err)
+	}
+	release()
+}
+
+func TestHostLimiterCancelWhileWaiting(t *testing.T) {
+	l, _ := newTestLimiter(1, 1, 2)
+	release, err := acquireNow(l, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	// the clock is stopped, so the second request waits until cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, "example.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want deadline exceeded while waiting for a token", err)
+	}
+	// the failed Acquire must give its slot back
+	h := l.host("example.com")
+	if n := len(h.running); n != 0 || h.users != 0 {
+		t.Errorf("%d requests still marked running, %d users", n, h.users)
+	}
+}
+
+func TestHostLimiterBlock(t *testing.T) {
+	l, clock := newTestLimiter(1000, 10, 2)
+	until := clock.now().Add(time.Hour)
+	l.Block("example.com", until)
+	// an earlier block doesn't shorten the current one
+	l.Block("example.com", clock.now().Add(time.Minute))
+
+	_, err := acquireNow(l, "example.com")
+	var blocked *HostBlockedError
+	if !errors.As(err, &blocked) {
+		t.Fatalf("got %v, want *HostBlockedError", err)
+	}
+	if blocked.Host != "example.com" || !blocked.Until.Equal(until) {
+		t.Errorf("got %s until %s, want example.com until %s", blocked.Host, blocked.Until, until)
+	}
+	if n := len(l.host("example.com").running); n != 0 {
+		t.Errorf("%d requests still marked running", n)
+	}
+
+	release, err := acquireNow(l, "other.example")
+	if err != nil {
+		t.Fatalf("other host: %v", err)
+	}
+	release()
+
+	clock.advance(time.Hour)
+	release, err = acquireNow(l, "example.com")
+	if err != nil {
+		t.Fatalf("after the block: %v", err)
+	}
+	release()
+}
+
+func TestHostLimiterEvictsIdleHosts(t *testing.T) {
+	l, clock := newTestLimiter(1, 2, 2)
+	for _, host := range []string{"idle.example", "busy.example", "drained.example"} {
+		release, err := acquireNow(l, host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if host != "busy.example" {
+			release()
+		}
+	}
+	l.Block("blocked.example", clock.now().Add(time.Hour))
+	h := l.host("drained.example")
+	h.tokens = -100
+
+	clock.advance(sweepInterval)
+	l.host("new.example")
+	for host, want := range map[string]bool{
+		"idle.example" : false,
+		"busy.example" : true,
+		"blocked.example" : true,
+		"drained.example" : true,
+		"new.example" : true,
+	} {
+		if _, ok := l.hosts[host]; ok != want {
+			t.Errorf("%s kept = %v, want %v", host, ok, want)
+		}
+	}
+
+	// nothing is dropped between sweeps
+	clock.advance(sweepInterval / 2)
+	l.host("other.example")
+	if _, ok := l.hosts["new.example"]; !ok {
+		t.Error("new.example dropped before the next sweep")
+	}
+}
+
+func TestLimitedTransportRedirect(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer target.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer origin.Close()
+	targetURL, err := url.Parse(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	originURL, err := url.Parse(origin.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, clock := newTestLimiter(1000, 10, 2)
+	client := &http.Client{Transport : &limitedTransport{Transport : &http.Transport{}, limiter : l}}
+	defer client.CloseIdleConnections()
+
+	res, err := client.Get(origin.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	for _, host := range []string{originURL.Host, targetURL.Host} {
+		h := l.host(host)
+		if h.tokens != 9 {
+			t.Errorf("%s has %.0f tokens left, want 9", host, h.tokens)
+		}
+		if n := len(h.running); n != 0 || h.users != 0 {
+			t.Errorf("%s: %d requests still marked running, %d users", host, n, h.users)
+		}
+	}
+
+	l.Block(targetURL.Host, clock.now().Add(time.Hour))
+	_, err = client.Get(origin.URL)
+	var blocked *HostBlockedError
+	if !errors.As(err, &blocked) || blocked.Host != targetURL.Host {
+		t.Fatalf("redirect to a blocked host: got %v, want *HostBlockedError for %s", err, targetURL.Host)
+	}
+}
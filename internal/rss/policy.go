@@ -0,0 +1,164 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// blockedNets are ranges that aren't covered by the netip.Addr predicates
+// used in checkAddr but must not be reachable from user supplied URLs either.
+var blockedNets = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	// NAT64 and 6to4 addresses embed an IPv4 address, which may be private
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// BlockedError is returned when a feed URL resolves to an address that
+// feeds may not be fetched from, such as a private network or the cloud
+// metadata service.
+type BlockedError struct {
+	Host string
+	Addr netip.Addr
+}
+
+func (e *BlockedError) Error() string {
+	if e.Host == e.Addr.String() {
+		return fmt.Sprintf("refusing to fetch from %s, it is a private or reserved address", e.Addr)
+	}
+	return fmt.Sprintf("refusing to fetch from %s, it resolves to the private or reserved address %s", e.Host, e.Addr)
+}
+
+// policy decides which hosts feeds may be fetched from. Public addresses are
+// always allowed; private ones only if they are on the allowlist. proxies
+// holds the host:port of the configured proxies, which may be dialed
+// wherever they are.
+type policy struct {
+	hosts map[string]bool
+	nets []netip.Prefix
+	proxies map[string]bool
+}
+
+// newPolicy parses an allowlist of hostnames, IP addresses and CIDR ranges.
+func newPolicy(allow []string) (*policy, error) {
+	p := &policy{hosts : map[string]bool{}, proxies : map[string]bool{}}
+	for _, entry := range allow {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			p.nets = append(p.nets, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			p.nets = append(p.nets, netip.PrefixFrom(addr, addr.BitLen()))
+		} else if strings.Contains(entry, "/") {
+			return nil, fmt.Errorf("invalid CIDR range '%s' in allowlist", entry)
+		} else {
+			p.hosts[strings.ToLower(strings.TrimSuffix(entry, "."))] = true
+		}
+	}
+	return p, nil
+}
+
+func (p *policy) allowsHost(host string) bool {
+	return p.hosts[strings.ToLower(strings.TrimSuffix(host, "."))]
+}
+
+// checkAddr fails for private, loopback, link-local and other reserved
+// addresses that aren't on the allowlist.
+func (p *policy) checkAddr(host string, addr netip.Addr) error {
+	addr = addr.Unmap()
+	for _, prefix := range p.nets {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+	blocked := addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast()
+	for _, prefix := range blockedNets {
+		blocked = blocked || prefix.Contains(addr)
+	}
+	if blocked {
+		return &BlockedError{Host : host, Addr : addr}
+	}
+	return nil
+}
+
+// dialContext wraps dial so every connection is checked against the policy
+// after DNS resolution, which covers redirects and DNS rebinding too.
+func (p *policy) dialContext(dialer net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if p.allowsHost(host) || p.proxies[strings.ToLower(address)] || strings.EqualFold(address, proxyAddr(ctx)) {
+			return dialer.DialContext(ctx, network, address)
+		}
+		checked := dialer
+		checked.ControlContext = func(ctx context.Context, network, address string, c syscall.RawConn) error {
+			ip, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
+				return err
+			}
+			return p.checkAddr(host, addr)
+		}
+		return checked.DialContext(ctx, network, address)
+	}
+}
+
+// checkURL resolves the host of a feed URL and checks all its addresses.
+func (p *policy) checkURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme '%s', feeds must be http or https", u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("URL '%s' has no host", rawURL)
+	}
+	if p.allowsHost(host) {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if err := p.checkAddr(host, addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRequest checks the URL of req. Behind a proxy the host may only
+// resolve on the proxy's side, so a failed lookup isn't an error there.
+func (p *policy) checkRequest(req *http.Request, proxied bool) error {
+	err := p.checkURL(req.Context(), req.URL.String())
+	var dnsErr *net.DNSError
+	if proxied && errors.As(err, &dnsErr) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,204 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestNewPolicy(t *testing.T) {
+	if _, err := newPolicy([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("invalid CIDR range was accepted")
+	}
+	p, err := newPolicy([]string{" Feeds.Internal. ", "", "10.1.0.0/16", "192.168.1.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"feeds.internal", "FEEDS.internal", "feeds.internal."} {
+		if !p.allowsHost(host) {
+			t.Errorf("host %s should be allowed", host)
+		}
+	}
+	if p.allowsHost("other.internal") {
+		t.Error("host other.internal should not be allowed")
+	}
+}
+
+func TestCheckAddr(t *testing.T) {
+	p, err := newPolicy([]string{"10.1.0.0/16", "192.168.1.5", "fd00::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addr    string
+		blocked bool
+	}{
+		{"93.184.216.34", false},
+		{"2606:2800:220:1:248:1893:25c8:1946", false},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"10.0.0.1", true},
+		{"172.16.5.4", true},
+		{"192.168.1.6", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fd12::1", true},
+		{"100.64.0.1", true},
+		{"198.18.0.1", true},
+		{"224.0.0.1", true},
+		{"255.255.255.255", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:169.254.169.254", true},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"64:ff9b:1::a9fe:a9fe", true},
+		{"64:ff9b:1:ffff::1", true},
+		{"2002:7f00:1::1", true},
+		{"2002:a9fe:a9fe::1", true},
+		{"2003::1", false},
+		// allowlisted
+		{"10.1.2.3", false},
+		{"192.168.1.5", false},
+		{"::ffff:192.168.1.5", false},
+		{"fd00::1", false},
+	}
+	for _, tt := range tests {
+		err := p.checkAddr("example.com", netip.MustParseAddr(tt.addr))
+		var blocked *BlockedError
+		if got := errors.As(err, &blocked); got != tt.blocked {
+			t.Errorf("checkAddr(%s) = %v, want blocked %v", tt.addr, err, tt.blocked)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	p, err := newPolicy([]string{"feeds.internal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://93.184.216.34/feed.xml", false},
+		{"http://[2606:2800:220:1:248:1893:25c8:1946]/feed", false},
+		{"http://127.0.0.1:8080/feed.xml", true},
+		{"http://[::1]/feed.xml", true},
+		{"http://169.254.169.254/latest/meta-data/", true},
+		{"http://feeds.internal/feed.xml", false},
+		{"ftp://93.184.216.34/feed.xml", true},
+		{"file:///etc/passwd", true},
+		{"http:///feed.xml", true},
+	}
+	for _, tt := range tests {
+		err := p.checkURL(context.Background(), tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkURL(%s) = %v, want error %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("can't listen on loopback:", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	addr := ln.Addr().String()
+	_, port, _ := net.SplitHostPort(addr)
+
+	proxied, err := WithProxy(context.Background(), "http://127.0.0.1:"+port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		allow   []string
+		proxy   string
+		ctx     context.Context
+		address string
+		blocked bool
+	}{
+		{"loopback", nil, "", context.Background(), addr, true},
+		{"allowlisted address", []string{"127.0.0.1"}, "", context.Background(), addr, false},
+		{"allowlisted range", []string{"127.0.0.0/8"}, "", context.Background(), addr, false},
+		{"allowlisted host", []string{"localhost"}, "", context.Background(), "localhost:" + port, false},
+		{"feed proxy", nil, "", proxied, addr, false},
+		{"feed proxy host on another port", nil, "", proxied, "127.0.0.1:1", true},
+		{"global proxy", nil, "http://" + addr, context.Background(), addr, false},
+		{"global proxy host on another port", nil, "http://" + addr, context.Background(), "127.0.0.1:1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newPolicy(tt.allow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.proxy != "" {
+				if _, err := globalProxy(tt.proxy, p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			conn, err := p.dialContext(net.Dialer{})(tt.ctx, "tcp", tt.address)
+			if conn != nil {
+				conn.Close()
+			}
+			var blocked *BlockedError
+			if errors.As(err, &blocked) != tt.blocked {
+				t.Errorf("dial %s = %v, want blocked %v", tt.address, err, tt.blocked)
+			}
+			if !tt.blocked && err != nil {
+				t.Errorf("dial %s: %v", tt.address, err)
+			}
+		})
+	}
+}
+
+func TestGlobalProxyIsNotAllowlisted(t *testing.T) {
+	tests := []struct {
+		proxy string
+		addr  string
+	}{
+		{"http://127.0.0.1:3128", "127.0.0.1:3128"},
+		{"http://Proxy.Internal", "proxy.internal:80"},
+		{"https://[fd00::1]", "[fd00::1]:443"},
+		{"socks5://10.0.0.1", "10.0.0.1:1080"},
+	}
+	for _, tt := range tests {
+		p, err := newPolicy(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := ParseProxy(tt.proxy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := globalProxy(tt.proxy, p); err != nil {
+			t.Fatal(err)
+		}
+		if !p.proxies[tt.addr] {
+			t.Errorf("%s: proxies = %v, want %s", tt.proxy, p.proxies, tt.addr)
+		}
+		if p.allowsHost(u.Hostname()) {
+			t.Errorf("%s: proxy host is on the allowlist", tt.proxy)
+		}
+		if host := u.Hostname(); host != "proxy.internal" {
+			feedURL := "http://" + net.JoinHostPort(host, "8080") + "/feed.xml"
+			if err := p.checkURL(context.Background(), feedURL); err == nil {
+				t.Errorf("%s: feed URL %s on the proxy host was allowed", tt.proxy, feedURL)
+			}
+		}
+	}
+}
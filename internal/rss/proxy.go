@@ -0,0 +1,107 @@
+package rss
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Direct is the proxy setting that disables proxies, including those from
+// the environment.
+const Direct = "direct"
+
+type feedProxyKey struct{}
+
+type feedProxy struct {
+	url *url.URL
+}
+
+// ParseProxy checks a proxy setting: an http, https, socks5 or socks5h URL,
+// or Direct, for which it returns nil.
+func ParseProxy(proxy string) (*url.URL, error) {
+	if proxy == Direct {
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme '%s', expected http, https, socks5 or socks5h", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy URL '%s' has no host", proxy)
+	}
+	return u, nil
+}
+
+// WithProxy makes FetchFeed calls with the returned context use proxy
+// instead of the configured one. proxy is parsed with ParseProxy.
+func WithProxy(ctx context.Context, proxy string) (context.Context, error) {
+	u, err := ParseProxy(proxy)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, feedProxyKey{}, feedProxy{url : u}), nil
+}
+
+// proxyAddr returns the address of the proxy set with WithProxy, if any.
+func proxyAddr(ctx context.Context) string {
+	fp, ok := ctx.Value(feedProxyKey{}).(feedProxy)
+	if !ok || fp.url == nil {
+		return ""
+	}
+	return dialAddr(fp.url)
+}
+
+// dialAddr returns the host:port the transport dials for proxy u, with the
+// scheme's default port when u has none.
+func dialAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http" : "80", "https" : "443", "socks5" : "1080", "socks5h" : "1080"}[u.Scheme]
+	}
+	return strings.ToLower(net.JoinHostPort(u.Hostname(), port))
+}
+
+// globalProxy returns the proxy function for the proxy option. Proxies come
+// from the operator, so p lets them be dialed even if they are on a private
+// network. That only covers connections to the proxy itself: feed URLs on
+// the same host are still checked.
+func globalProxy(proxy string, p *policy) (func(*http.Request) (*url.URL, error), error) {
+	if proxy == "" {
+		env := httpproxy.FromEnvironment()
+		for _, proxy := range []string{env.HTTPProxy, env.HTTPSProxy} {
+			if proxy != "" && !strings.Contains(proxy, "://") {
+				// like net/http, read "host:port" as an http proxy
+				proxy = "http://" + proxy
+			}
+			if u, err := url.Parse(proxy); err == nil && u.Hostname() != "" {
+				p.proxies[dialAddr(u)] = true
+			}
+		}
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := ParseProxy(proxy)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return func(*http.Request) (*url.URL, error) { return nil, nil }, nil
+	}
+	p.proxies[dialAddr(u)] = true
+	return http.ProxyURL(u), nil
+}
+
+// usesProxy reports whether req will be sent through a proxy.
+func usesProxy(t *http.Transport, req *http.Request) bool {
+	u, err := t.Proxy(req)
+	return err == nil && u != nil
+}
@@ -0,0 +1,133 @@
+package rss
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// urlAttrs are the HTML attributes holding a URL that are resolved in item
+// descriptions. srcset is handled separately since it holds a list.
+var urlAttrs = map[string]bool{
+	"href" : true,
+	"src" : true,
+	"poster" : true,
+}
+
+// resolveURLs makes the links of a feed absolute. Relative URLs are resolved
+// against xml:base if the feed sets it, otherwise against the channel link,
+// and finally against the URL the feed was fetched from.
+func resolveURLs(feed *RSSFeed, fetched *url.URL) {
+	base := resolveBase(fetched, feed.Base)
+	base = resolveBase(base, feed.Channel.Base)
+	feed.Channel.Link = resolveRef(base, feed.Channel.Link)
+	if feed.Base == "" && feed.Channel.Base == "" {
+		if link, err := url.Parse(feed.Channel.Link); err == nil && (link.Scheme == "http" || link.Scheme == "https") && link.Host != "" {
+			base = link
+		}
+	}
+	for i := range feed.Channel.Item {
+		item := &feed.Channel.Item[i]
+		itemBase := resolveBase(base, item.Base)
+		item.Link = resolveRef(itemBase, item.Link)
+		for j := range item.Enclosures {
+			item.Enclosures[j].URL = resolveRef(itemBase, item.Enclosures[j].URL)
+		}
+		item.Description = resolveHTML(itemBase, item.Description)
+	}
+}
+
+// resolveBase applies an xml:base attribute, which may itself be relative.
+func resolveBase(base *url.URL, xmlBase string) *url.URL {
+	xmlBase = strings.TrimSpace(xmlBase)
+	if xmlBase == "" {
+		return base
+	}
+	u, err := url.Parse(xmlBase)
+	if err != nil {
+		return base
+	}
+	return base.ResolveReference(u)
+}
+
+// resolveRef returns ref made absolute, or unchanged if it can't be parsed.
+func resolveRef(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
+
+// resolveHTML rewrites the URLs of links, images and media in an HTML
+// fragment. Tags without relative URLs are copied as they are.
+func resolveHTML(base *url.URL, fragment string) string {
+	if !strings.Contains(fragment, "<") {
+		return fragment
+	}
+	var out bytes.Buffer
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				// keep whatever the tokenizer couldn't make sense of
+				out.Write(z.Raw())
+			}
+			return out.String()
+		}
+		raw := z.Raw()
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			out.Write(raw)
+			continue
+		}
+		// Token copies the tag, so raw has to be saved first
+		saved := append([]byte(nil), raw...)
+		token := z.Token()
+		changed := false
+		for i, attr := range token.Attr {
+			var resolved string
+			switch {
+			case attr.Namespace != "":
+				continue
+			case urlAttrs[attr.Key]:
+				resolved = resolveRef(base, attr.Val)
+			case attr.Key == "srcset":
+				resolved = resolveSrcset(base, attr.Val)
+			default:
+				continue
+			}
+			if resolved != attr.Val {
+				token.Attr[i].Val = resolved
+				changed = true
+			}
+		}
+		if changed {
+			out.WriteString(token.String())
+		} else {
+			out.Write(saved)
+		}
+	}
+}
+
+// resolveSrcset resolves each candidate of a srcset attribute, e.g.
+// "a.png 1x, b.png 2x".
+func resolveSrcset(base *url.URL, srcset string) string {
+	candidates := strings.Split(srcset, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = resolveRef(base, fields[0])
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
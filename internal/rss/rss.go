@@ -2,58 +2,215 @@ package rss
 
 import (
 	"net/http"
-	"encoding/xml"
 	"html"
 	"context"
-	"io"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// maxRetryAfter caps how long a server can ask us to stay away.
+const maxRetryAfter = 24 * time.Hour
+
 type RSSFeed struct {
+	Base string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
 	Channel struct {
+		Base        string    `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
 		Title       string    `xml:"title"`
 		Link        string    `xml:"link"`
 		Description string    `xml:"description"`
 		Item        []RSSItem `xml:"item"`
+		TTL         string    `xml:"ttl"`
+		UpdatePeriod    string `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+		SkipHours []string `xml:"skipHours>hour"`
+		SkipDays  []string `xml:"skipDays>day"`
 	} `xml:"channel"`
+	// MovedTo is the URL the feed was permanently redirected to, if any.
+	MovedTo string `xml:"-"`
 }
 
 type RSSItem struct {
+	Base        string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
+	GUID        GUID   `xml:"guid"`
+	Enclosures  []Enclosure `xml:"enclosure"`
+}
+
+// GUID identifies an item. Unless IsPermaLink is "false" it is also the
+// item's URL.
+type GUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink string `xml:"isPermaLink,attr"`
+}
+
+// URL returns the link of the item, falling back to its guid when that is
+// an absolute http(s) permalink. It returns "" for items without either,
+// which RSS 2.0 allows.
+func (item *RSSItem) URL() string {
+	if link := strings.TrimSpace(item.Link); link != "" {
+		return link
+	}
+	if item.GUID.IsPermaLink == "false" {
+		return ""
+	}
+	guid := strings.TrimSpace(item.GUID.Value)
+	u, err := url.Parse(guid)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return guid
+}
+
+// Enclosure is a file attached to an item, such as a podcast episode.
+type Enclosure struct {
+	URL    string `xml:"url,attr"`
+	Length string `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// StatusError is returned by FetchFeed when the server doesn't answer with a
+// 2xx status. RetryAfter is set when the response had a Retry-After header.
+type StatusError struct {
+	URL string
+	StatusCode int
+	Status string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("fetching %s: %s, retry after %v", e.URL, e.Status, e.RetryAfter)
+	}
+	return fmt.Sprintf("fetching %s: %s", e.URL, e.Status)
+}
+
+// Temporary reports whether the request may succeed if retried later.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Permanent reports whether the feed is missing rather than temporarily
+// broken.
+func (e *StatusError) Permanent() bool {
+	return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
 }
 
-func FetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+// FetchFeed downloads and parses a feed. creds may be nil.
+func FetchFeed(ctx context.Context, feedURL string, creds *Credentials) (*RSSFeed, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", "gator")
+	client, opts, urlPolicy := currentClient()
+	// the dialer checks every connection, but behind a proxy only the proxy
+	// is dialed, so check where the URL points first
+	proxied := usesProxy(client.Transport.(*limitedTransport).Transport, req)
+	if err := urlPolicy.checkRequest(req, proxied); err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", opts.UserAgent)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	req = creds.apply(req)
 
-	client := http.Client{}
+	// the client's transport waits for the limiter before every request,
+	// including each redirect
 	res, err := client.Do(req)
 	if err != nil {
+		var blocked *HostBlockedError
+		if errors.As(err, &blocked) {
+			return nil, blocked
+		}
 		return nil, err
 	}
 	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		statusErr := &StatusError{
+			URL : feedURL,
+			StatusCode : res.StatusCode,
+			Status : res.Status,
+			RetryAfter : parseRetryAfter(res.Header.Get("Retry-After"), time.Now()),
+		}
+		if statusErr.RetryAfter > 0 && statusErr.Temporary() {
+			// block the host that answered, which a redirect may have changed
+			DefaultLimiter.Block(res.Request.URL.Host, time.Now().Add(statusErr.RetryAfter))
+		}
+		return nil, statusErr
+	}
 
-	body, err := io.ReadAll(res.Body)
+	body, err := readBody(res, opts.MaxBodySize)
 	if err != nil {
 		return nil, err
 	}
 	
+	decoder, err := newDecoder(body, res.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
 	var feed RSSFeed
-	if err := xml.Unmarshal(body, &feed); err != nil {
+	if err := decoder.Decode(&feed); err != nil {
 		return nil, err
 	}
 
+	feed.MovedTo = permanentRedirect(res)
+
 	feed.Channel.Title = html.UnescapeString(feed.Channel.Title)
 	feed.Channel.Description = html.UnescapeString(feed.Channel.Description)
 	for i, _ := range feed.Channel.Item {
 		feed.Channel.Item[i].Title = html.UnescapeString(feed.Channel.Item[i].Title)
 		feed.Channel.Item[i].Description = html.UnescapeString(feed.Channel.Item[i].Description)
 	}
+	// res.Request is the last request made, after any redirects
+	resolveURLs(&feed, res.Request.URL)
 
 	return &feed, nil
-}
\ No newline at end of file
+}
+
+// Gone reports whether the server said the feed was removed for good.
+func (e *StatusError) Gone() bool {
+	return e.StatusCode == http.StatusGone
+}
+
+// permanentRedirect returns the last URL reached from the original request
+// through permanent redirects only, or "" if the first redirect (if any) was
+// temporary.
+func permanentRedirect(res *http.Response) string {
+	var chain []*http.Request
+	for req := res.Request; req != nil; {
+		chain = append(chain, req)
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+	moved := ""
+	for i := len(chain) - 2; i >= 0; i-- {
+		code := chain[i].Response.StatusCode
+		if code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+			break
+		}
+		moved = chain[i].URL.String()
+	}
+	return moved
+}
+
+// parseRetryAfter reads a Retry-After header, which is either a number of
+// seconds or an HTTP date. It returns zero if the header is missing or bad.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	var d time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		d = time.Duration(seconds) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		d = t.Sub(now)
+	}
+	return min(max(d, 0), maxRetryAfter)
+}
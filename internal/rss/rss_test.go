@@ -0,0 +1,102 @@
+package rss
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// redirected builds the response net/http returns after following codes,
+// one redirect status per hop, from https://example.com/0 to .../n.
+func redirected(t *testing.T, codes []int) *http.Response {
+	t.Helper()
+	hop := func(i int) *url.URL {
+		u, err := url.Parse("https://example.com/" + string(rune('0'+i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	req := &http.Request{URL : hop(0)}
+	for i, code := range codes {
+		req = &http.Request{
+			URL : hop(i+1),
+			Response : &http.Response{StatusCode : code, Request : req},
+		}
+	}
+	return &http.Response{StatusCode : http.StatusOK, Request : req}
+}
+
+func TestPermanentRedirect(t *testing.T) {
+	tests := []struct {
+		name  string
+		codes []int
+		want  string
+	}{
+		{"no redirect", nil, ""},
+		{"moved permanently", []int{301}, "https://example.com/1"},
+		{"permanent redirect", []int{308}, "https://example.com/1"},
+		{"two permanent hops", []int{301, 308}, "https://example.com/2"},
+		{"permanent then temporary", []int{301, 302}, "https://example.com/1"},
+		{"temporary then permanent", []int{302, 301}, ""},
+		{"temporary", []int{307}, ""},
+		{"see other", []int{303}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := permanentRedirect(redirected(t, tt.codes)); got != tt.want {
+				t.Errorf("permanentRedirect after %v = %q, want %q", tt.codes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"missing", "", 0},
+		{"seconds", "120", 2 * time.Minute},
+		{"zero seconds", "0", 0},
+		{"negative seconds", "-5", 0},
+		{"http date", "Wed, 01 May 2024 12:30:00 GMT", 30 * time.Minute},
+		{"date in the past", "Wed, 01 May 2024 11:00:00 GMT", 0},
+		{"capped seconds", "604800", maxRetryAfter},
+		{"capped date", "Wed, 08 May 2024 12:00:00 GMT", maxRetryAfter},
+		{"garbage", "soon", 0},
+		{"fractional seconds", "1.5", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, now); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestItemURL(t *testing.T) {
+	tests := []struct {
+		name string
+		item RSSItem
+		want string
+	}{
+		{"link", RSSItem{Link : " https://example.com/post ", GUID : GUID{Value : "https://example.com/?p=1"}}, "https://example.com/post"},
+		{"permalink guid", RSSItem{GUID : GUID{Value : "https://example.com/?p=1"}}, "https://example.com/?p=1"},
+		{"explicit permalink guid", RSSItem{GUID : GUID{Value : "https://example.com/?p=1", IsPermaLink : "true"}}, "https://example.com/?p=1"},
+		{"guid that isn't a permalink", RSSItem{GUID : GUID{Value : "https://example.com/?p=1", IsPermaLink : "false"}}, ""},
+		{"guid that isn't a URL", RSSItem{GUID : GUID{Value : "42"}}, ""},
+		{"tag guid", RSSItem{GUID : GUID{Value : "tag:example.com,2024:1"}}, ""},
+		{"relative guid", RSSItem{GUID : GUID{Value : "/posts/1"}}, ""},
+		{"neither", RSSItem{Title : "No link"}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.item.URL(); got != tt.want {
+			t.Errorf("%s: URL() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
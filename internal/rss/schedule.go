@@ -0,0 +1,53 @@
+package rss
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+var updatePeriods = map[string]time.Duration{
+	"hourly" : time.Hour,
+	"daily" : 24 * time.Hour,
+	"weekly" : 7 * 24 * time.Hour,
+	"monthly" : 30 * 24 * time.Hour,
+	"yearly" : 365 * 24 * time.Hour,
+}
+
+// MinInterval returns the shortest interval between fetches the feed asks
+// for through <ttl> or <sy:updatePeriod> and <sy:updateFrequency>, or zero
+// if it doesn't say.
+func (f *RSSFeed) MinInterval() time.Duration {
+	var interval time.Duration
+	if ttl, err := strconv.Atoi(strings.TrimSpace(f.Channel.TTL)); err == nil && ttl > 0 {
+		interval = time.Duration(ttl) * time.Minute
+	}
+	if period, ok := updatePeriods[strings.ToLower(strings.TrimSpace(f.Channel.UpdatePeriod))]; ok {
+		frequency, err := strconv.Atoi(strings.TrimSpace(f.Channel.UpdateFrequency))
+		if err != nil || frequency < 1 {
+			frequency = 1
+		}
+		if p := period / time.Duration(frequency); p > interval {
+			interval = p
+		}
+	}
+	return interval
+}
+
+// Skipped reports whether the feed asks not to be fetched at t through
+// <skipHours> and <skipDays>, which are both in GMT.
+func (f *RSSFeed) Skipped(t time.Time) bool {
+	t = t.UTC()
+	for _, h := range f.Channel.SkipHours {
+		// some feeds use 24 for midnight
+		if hour, err := strconv.Atoi(strings.TrimSpace(h)); err == nil && hour%24 == t.Hour() {
+			return true
+		}
+	}
+	for _, d := range f.Channel.SkipDays {
+		if strings.EqualFold(strings.TrimSpace(d), t.Weekday().String()) {
+			return true
+		}
+	}
+	return false
+}
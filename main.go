@@ -9,52 +9,61 @@ import (
 	"errors"
 	"context"
 	"strconv"
+	"strings"
 	"database/sql"
 	"github.com/andrei-himself/gator/internal/config"
 	"github.com/andrei-himself/gator/internal/database"
-	"github.com/andrei-himself/gator/internal/rss"
+	"github.com/andrei-himself/gator/internal/output"
 	"github.com/google/uuid"
 )
 
+// version is set when building a release with
+// -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
 type state struct {
 	cfg *config.Config
 	db *database.Queries
+	conn *sql.DB
+	sessionUser string
 }
 
-type command struct {
-	name string
-	args []string
-}
-
-type commands struct {
-	m map[string]func(*state, command) error
+// connect opens the database of the active profile, closing any previous
+// connection.
+func (s *state) connect() error {
+	db, err := sql.Open("postgres", s.cfg.DBURL)
+	if err != nil {
+		return err
+	}
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.conn = db
+	s.db = database.New(db)
+	return nil
 }
 
-func (c *commands) run(s *state, cmd command) error {
-	com, ok := c.m[cmd.name]
-	if ok != true {
-		return fmt.Errorf(fmt.Sprintf("command '%v' not found", cmd.name))
+// currentUserName returns the user switched to in this shell session, falling
+// back to the one saved in the config file.
+func (s *state) currentUserName() string {
+	if s.sessionUser != "" {
+		return s.sessionUser
 	}
-	return com(s, cmd)
+	return s.cfg.CurrentUserName
 }
 
-func (c *commands) register(name string, f func(*state, command) error) {
-	c.m[name] = f
-} 
-
 func handlerLogin(s *state, cmd command) error {
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("login command expects username as an argument")
-	}
 	name := cmd.args[0]
-	_, err := s.db.GetUser(context.Background(), name)
+	user, err := s.db.GetUser(context.Background(), name)
 	if errors.Is(err, sql.ErrNoRows) {
-		fmt.Fprintln(os.Stderr, "username doesn't exist in the database")
-		os.Exit(1)
+		return fmt.Errorf("username doesn't exist in the database")
 	} else if err != nil {
 		return err
 	}
-	err = s.cfg.SetUser(name)
+	if err := verifyPassword(user, "Password: "); err != nil {
+		return err
+	}
+	err = saveLogin(s, user)
 	if err != nil {
 		return err
 	} 
@@ -63,21 +72,33 @@ func handlerLogin(s *state, cmd command) error {
 }
 
 func handlerRegister(s *state, cmd command) error {
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("register command expects username as an argument")
-	}
 	name := cmd.args[0]
+	var hash sql.NullString
+	if cmd.boolFlag("password") {
+		var err error
+		hash, err = readNewPassword()
+		if err != nil {
+			return err
+		}
+	}
+	// the first user of a database becomes its admin
+	admins, err := s.db.CountAdmins(context.Background())
+	if err != nil {
+		return err
+	}
 	data := database.CreateUserParams{
 		ID : uuid.New(),
 		CreatedAt : time.Now(),
 		UpdatedAt : time.Now(),
 		Name : name,
+		PasswordHash : hash,
+		IsAdmin : admins == 0,
 	}
-	_, err := s.db.CreateUser(context.Background(), data)
+	user, err := s.db.CreateUser(context.Background(), data)
 	if err != nil {
 		return err
 	}
-	err = s.cfg.SetUser(name)
+	err = saveLogin(s, user)
 	if err != nil {
 		return err
 	}
@@ -85,7 +106,7 @@ func handlerRegister(s *state, cmd command) error {
 	return nil
 }
 
-func handlerReset(s *state, cmd command) error {
+func handlerReset(s *state, cmd command, user database.User) error {
 	err := s.db.DeleteUsers(context.Background())
 	if err != nil {
 		return err
@@ -98,6 +119,10 @@ func handlerReset(s *state, cmd command) error {
 	if err != nil {
 		return err
 	}
+	err = s.db.DeleteOrphanPosts(context.Background())
+	if err != nil {
+		return err
+	}
 	fmt.Println("Users, feeds, and feed follows deleted successfully!")
 	return nil
 }
@@ -108,41 +133,36 @@ func handlerUsers(s *state, cmd command) error {
 	if err != nil {
 		return err
 	}
-	current := s.cfg.CurrentUserName
+	current := s.currentUserName()
+	table := output.Table{
+		Columns : []output.Column{
+			{Name : "name"},
+			{Name : "current"},
+			{Name : "admin"},
+			{Name : "created_at"},
+		},
+	}
 	for _, v := range users {
-		if v.Name == current {
-			fmt.Println("*", current, "(current)")
-			continue
-		}
-		fmt.Println("*", v.Name)
+		table.Add(v.Name, v.Name == current, v.IsAdmin, v.CreatedAt)
 	}
-	return nil
+	return printTable(cmd, table)
 }
 
-func handlerAgg(s *state, cmd command) error {
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("agg command expects time between requests as an argument")
-	}
-	
-	duration, err := time.ParseDuration(cmd.args[0])
+func handlerAddfeed(s *state, cmd command, user database.User) error {
+	name := cmd.args[0]
+	url, err := canonicalURL(s, cmd.args[1])
 	if err != nil {
 		return err
 	}
-
-	fmt.Printf("Collecting feeds every %v\n", duration)
-	ticker := time.NewTicker(duration)
-	for ; ; <-ticker.C {
-		scrapeFeeds(s)
-	} 
-	return nil
-}
-
-func handlerAddfeed(s *state, cmd command, user database.User) error {
-	if len(cmd.args) < 2 {
-		return fmt.Errorf("addfeed command expects name and url as arguments")
+	if err := checkFeedURL(s, url); err != nil {
+		return err
+	}
+	existing, err := getFeedByURL(s, url)
+	if err == nil {
+		return fmt.Errorf("feed '%s' already exists as %s, follow it with 'gator follow %s'", existing.Name, existing.Url, existing.Url)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
 	}
-	name := cmd.args[0]
-	url := cmd.args[1]
 
 	feed := database.CreateFeedParams{
 		ID : uuid.New(),
@@ -157,7 +177,7 @@ func handlerAddfeed(s *state, cmd command, user database.User) error {
 	if err != nil {
 		return err
 	}
-	fmt.Printf("%+v\n", createdFeed)
+	fmt.Printf("Feed '%s' added for %s\n", createdFeed.Name, createdFeed.Url)
 
 	followCmd := command{
 		name : "follow",
@@ -177,26 +197,39 @@ func handlerFeeds(s *state, cmd command) error {
 	if err != nil {
 		return err
 	}
+	table := output.Table{
+		Columns : []output.Column{
+			{Name : "name", MaxWidth : 40},
+			{Name : "url", MaxWidth : 60},
+			{Name : "owner"},
+			{Name : "created_at"},
+			{Name : "last_fetched_at"},
+			{Name : "next_fetch_at"},
+			{Name : "interval"},
+			{Name : "status"},
+			{Name : "pause_reason", MaxWidth : 40},
+		},
+	}
 	for _, v := range feeds {
-		fmt.Printf("%+v\n", v)
 		user, err := s.db.GetUserByID(context.Background(), v.UserID)
 		if err != nil {
 			return err
 		}
-		fmt.Println(user.Name)
+		status := "active"
+		if v.PausedAt.Valid {
+			status = "paused"
+		}
+		table.Add(v.Name, v.Url, user.Name, v.CreatedAt, nullTime(v.LastFetchedAt), nullTime(v.NextFetchAt), formatInterval(v.FetchInterval), status, nullString(v.PauseReason))
 	}
-	
-	return nil
+	return printTable(cmd, table)
 }
 
 func handlerFollow(s *state, cmd command, user database.User) error {
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("follow command expects url as an argument")
-	}
-
 	url := cmd.args[0]
-	feed, err := s.db.GetFeedByURL(context.Background(), url)
-	if err != nil {
+	feed, err := getFeedByURL(s, url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("feed '%s' doesn't exist in the database", url)
+	} else if err != nil {
 		return err
 	}
 
@@ -217,22 +250,30 @@ func handlerFollow(s *state, cmd command, user database.User) error {
 }
 
 func handlerFollowing(s *state, cmd command, user database.User) error {
-
 	feedFollowsForUser, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("%+v", feedFollowsForUser)
-	return nil
+	table := output.Table{
+		Columns : []output.Column{
+			{Name : "feed_name", MaxWidth : 40},
+			{Name : "feed_url", MaxWidth : 60},
+			{Name : "folder", MaxWidth : 20},
+			{Name : "followed_at"},
+		},
+	}
+	for _, v := range feedFollowsForUser {
+		table.Add(v.FeedName, v.FeedUrl, v.Folder.String, v.CreatedAt)
+	}
+	return printTable(cmd, table)
 }
 
 func handlerUnfollow(s *state, cmd command, user database.User) error {
-	if len(cmd.args) == 0 {
-		return fmt.Errorf("unfollow command expects feed url as an argument")
-	}
 	url := cmd.args[0]
-	feed, err := s.db.GetFeedByURL(context.Background(), url)
-	if err != nil {
+	feed, err := getFeedByURL(s, url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("feed '%s' doesn't exist in the database", url)
+	} else if err != nil {
 		return err
 	}
 
@@ -244,143 +285,529 @@ func handlerUnfollow(s *state, cmd command, user database.User) error {
 	return s.db.DeleteFeedFollow(context.Background(), params)
 }
 
+func handlerFolder(s *state, cmd command, user database.User) error {
+	url := cmd.args[0]
+	feed, err := getFeedByURL(s, url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("feed '%s' doesn't exist in the database", url)
+	} else if err != nil {
+		return err
+	}
+	folder := ""
+	if len(cmd.args) > 1 {
+		folder = strings.TrimSpace(cmd.args[1])
+	}
+	updated, err := s.db.SetFeedFollowFolder(context.Background(), database.SetFeedFollowFolderParams{
+		UserID : user.ID,
+		FeedID : feed.ID,
+		Folder : sql.NullString{String: folder, Valid: folder != ""},
+		UpdatedAt : time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return fmt.Errorf("%s doesn't follow feed '%s'", user.Name, feed.Name)
+	}
+	if folder == "" {
+		fmt.Printf("Feed '%s' is no longer in a folder\n", feed.Name)
+	} else {
+		fmt.Printf("Feed '%s' is now in folder '%s'\n", feed.Name, folder)
+	}
+	return nil
+}
+
 func handlerBrowse(s *state, cmd command, user database.User) error {
 	var limit int32
 	limit = 2
-	converted, err := strconv.ParseInt(cmd.args[0], 10, 32)
-	if err == nil {
+	if len(cmd.args) > 0 {
+		converted, err := strconv.ParseInt(cmd.args[0], 10, 32)
+		if err != nil || converted < 1 {
+			return fmt.Errorf("browse limit must be a positive number, got '%s'", cmd.args[0])
+		}
 		limit = int32(converted)
 	}
 
-	posts, err := s.db.GetPostsForUser(context.Background(), limit)
+	params := database.GetPostsForUserParams{
+		UserID : user.ID,
+		Limit : limit,
+	}
+	posts, err := s.db.GetPostsForUser(context.Background(), params)
 	if err != nil {
 		return err
 	}
 
+	if cmd.boolFlag("full") {
+		if format := cmd.flag("output"); format != "" && format != output.FormatTable {
+			return fmt.Errorf("--full prints text and can't be combined with --output %s", format)
+		}
+		for i, v := range posts {
+			if i > 0 {
+				fmt.Println()
+				fmt.Println(strings.Repeat("─", textWidth()))
+				fmt.Println()
+			}
+			printPost(os.Stdout, v.Title, v.FeedNames, v.PublishedAt, v.Url, v.Description)
+		}
+		return nil
+	}
+
+	table := output.Table{
+		Columns : []output.Column{
+			{Name : "published_at"},
+			{Name : "feed", MaxWidth : 20},
+			{Name : "title", MaxWidth : 60},
+			{Name : "url"},
+		},
+	}
 	for _, v := range posts {
-		fmt.Printf("%+v\n", v)
+		table.Add(v.PublishedAt, v.FeedNames, nullString(v.Title), v.Url)
 	}
+	return printTable(cmd, table)
+}
 
-	return nil
+func printTable(cmd command, table output.Table) error {
+	return output.Write(os.Stdout, cmd.flag("output"), table)
+}
+
+func nullString(v sql.NullString) any {
+	if !v.Valid {
+		return nil
+	}
+	return v.String
+}
+
+func nullTime(v sql.NullTime) any {
+	if !v.Valid {
+		return nil
+	}
+	return v.Time
 }
 
 func middlewareLoggedIn(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
 	
 	return func(s *state,cmd command) error{
-		username := s.cfg.CurrentUserName
+		username := s.currentUserName()
 		user, err := s.db.GetUser(context.Background(), username)
 		if err != nil {
 			return err
 		}
+		if err := authorize(s, user); err != nil {
+			return err
+		}
 
 		return handler(s, cmd, user)
 	}
 }
 
-func scrapeFeeds(s *state) error {
-	nextFeedToFetch, err := s.db.GetNextFeedToFetch(context.Background())
-	if err != nil {
-		return err
-	}
-
-	markParams := database.MarkFeedFetchedParams{
-		ID : nextFeedToFetch.ID,
-		LastFetchedAt : sql.NullTime{Time: time.Now(), Valid: true},
-	}
-	err = s.db.MarkFeedFetched(context.Background(), markParams)
-	if err != nil {
-		return err
-	}
-	
-	feed, err := rss.FetchFeed(context.Background(), nextFeedToFetch.Url)
-	if err != nil {
-		return err
-	}
-
-	for _, v := range feed.Channel.Item {
-		t, ok := parsePubDate(v.PubDate)
-		if !ok {
-			fmt.Println("no valid publish date for post:\n", v)
-			continue
-		}
-
-		postParams := database.CreatePostParams{
-			ID : uuid.New(),
-			CreatedAt : time.Now(),
-			UpdatedAt : time.Now(),
-			Title : sql.NullString{String: v.Title, Valid: true},
-			Url : v.Link,
-			Description : sql.NullString{String: v.Description, Valid: true},
-			PublishedAt : t,
-			FeedID : nextFeedToFetch.ID,
+// middlewareAdmin is middlewareLoggedIn for commands only admins may run.
+func middlewareAdmin(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
+	return middlewareLoggedIn(func(s *state, cmd command, user database.User) error {
+		if !user.IsAdmin {
+			return fmt.Errorf("%s command requires an admin, '%s' isn't one", cmd.name, user.Name)
 		}
-		_, err := s.db.CreatePost(context.Background(), postParams)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func parsePubDate(s string) (time.Time, bool) {
-    layouts := []string{
-        time.RFC1123Z,
-        time.RFC1123,
-        time.RFC822Z,
-        time.RFC822,
-        time.RFC3339,
-    }
-    for _, layout := range layouts {
-        if t, err := time.Parse(layout, s); err == nil {
-            return t, true
-        }
-    }
-    return time.Time{}, false
+		return handler(s, cmd, user)
+	})
 }
 
 func main() {
-	var s state
-	conf, err := config.Read()
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	} 
-	s.cfg = &conf
-
-	db, err := sql.Open("postgres", s.cfg.DBURL)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
-	dbQueries := database.New(db)
-	s.db = dbQueries
-
 	var commands commands
-	commands.m = map[string]func(*state, command) error{}
-	commands.register("login", handlerLogin)
-	commands.register("register", handlerRegister)
-	commands.register("reset", handlerReset)
-	commands.register("users", handlerUsers)
-	commands.register("agg", handlerAgg)
-	commands.register("addfeed", middlewareLoggedIn(handlerAddfeed))
-	commands.register("feeds", handlerFeeds)
-	commands.register("follow", middlewareLoggedIn(handlerFollow))
-	commands.register("following", middlewareLoggedIn(handlerFollowing))
-	commands.register("unfollow", middlewareLoggedIn(handlerUnfollow))
-	commands.register("browse", middlewareLoggedIn(handlerBrowse))
+	commands.m = map[string]commandDef{}
+	commands.registerGlobal(flagDef{
+		name : "output",
+		value : "format",
+		usage : "Output format for listings: table, json, jsonl, csv or tsv",
+		check : output.ValidFormat,
+		choices : output.Formats,
+	})
+	commands.registerGlobal(flagDef{
+		name : "profile",
+		value : "name",
+		usage : "Config profile to use for this command",
+		apply : applyProfile,
+	})
+	commands.register(commandDef{
+		name : "help",
+		args : []string{"[command...]"},
+		description : "Show available commands or help for one command",
+		handler : commands.handlerHelp,
+		complete : commands.completeCommandNames,
+		offline : true,
+	})
+	commands.register(commandDef{
+		name : "register",
+		args : []string{"username"},
+		flags : []flagDef{
+			{name : "password", usage : "Prompt for a password to protect the account"},
+		},
+		description : "Create a new user and set it as current",
+		handler : handlerRegister,
+	})
+	commands.register(commandDef{
+		name : "login",
+		args : []string{"username"},
+		description : "Set an existing user as current, asking for their password if they have one",
+		handler : handlerLogin,
+		complete : completeUsernames,
+	})
+	commands.register(commandDef{
+		name : "passwd",
+		flags : []flagDef{
+			{name : "remove", usage : "Remove the password instead of changing it"},
+		},
+		description : "Set or change the current user's password",
+		handler : middlewareLoggedIn(handlerPasswd),
+	})
+	commands.register(commandDef{
+		name : "users",
+		description : "List all users (marks the current one and admins)",
+		handler : handlerUsers,
+		subcommands : []commandDef{
+			{
+				name : "delete",
+				args : []string{"name"},
+				description : "Delete a user with their feeds and follows (admins only for other users)",
+				handler : middlewareLoggedIn(handlerUsersDelete),
+				complete : completeUsernames,
+			},
+			{
+				name : "rename",
+				args : []string{"old", "new"},
+				description : "Rename a user (admins only for other users)",
+				handler : middlewareLoggedIn(handlerUsersRename),
+				complete : completeUsernames,
+			},
+			{
+				name : "admin",
+				args : []string{"name"},
+				flags : []flagDef{
+					{name : "revoke", usage : "Take admin rights away instead of granting them"},
+				},
+				description : "Make a user an admin (admins only)",
+				handler : middlewareAdmin(handlerUsersAdmin),
+				complete : completeUsernames,
+			},
+		},
+	})
+	commands.register(commandDef{
+		name : "reset",
+		description : "Delete all users, feeds, and follows (admins only)",
+		handler : middlewareAdmin(handlerReset),
+	})
+	commands.register(commandDef{
+		name : "canonicalize",
+		description : "Rewrite stored feed and post URLs in canonical form, merging duplicates (admins only)",
+		handler : middlewareAdmin(handlerCanonicalize),
+	})
+	commands.register(commandDef{
+		name : "addfeed",
+		args : []string{"name", "url"},
+		description : "Add a new feed and follow it",
+		handler : middlewareLoggedIn(handlerAddfeed),
+	})
+	commands.register(commandDef{
+		name : "feeds",
+		description : "List all feeds with owners",
+		handler : handlerFeeds,
+	})
+	commands.register(commandDef{
+		name : "feed",
+		description : "Change or remove a feed you own (admins can change any feed)",
+		subcommands : []commandDef{
+			{
+				name : "rm",
+				args : []string{"url"},
+				description : "Remove a feed with its posts and follows",
+				handler : middlewareLoggedIn(handlerFeedRemove),
+				complete : completeFeedURLs,
+			},
+			{
+				name : "rename",
+				args : []string{"url", "name"},
+				description : "Change the name of a feed",
+				handler : middlewareLoggedIn(handlerFeedRename),
+				complete : completeFeedURLs,
+			},
+			{
+				name : "set-url",
+				args : []string{"old", "new"},
+				flags : []flagDef{
+					{name : "keep-credentials", usage : "Keep the feed's credentials even if the new URL is on another host"},
+				},
+				description : "Change the URL a feed is fetched from",
+				handler : middlewareLoggedIn(handlerFeedSetURL),
+				complete : completeFeedURLs,
+			},
+			{
+				name : "transfer",
+				args : []string{"url", "user"},
+				flags : []flagDef{
+					{name : "keep-credentials", usage : "Let the new owner keep using the feed's credentials"},
+				},
+				description : "Give ownership of a feed to another user",
+				handler : middlewareLoggedIn(handlerFeedTransfer),
+				complete : completeFeedThenUser,
+			},
+			{
+				name : "interval",
+				args : []string{"url", "interval"},
+				description : "Set how often a feed is fetched (e.g. 30m, 6h), or 'auto' to adapt to its posts",
+				handler : middlewareLoggedIn(handlerFeedInterval),
+				complete : completeFeedInterval,
+			},
+			{
+				name : "proxy",
+				args : []string{"url", "proxy"},
+				description : "Fetch a feed through a proxy URL, 'direct' for none or 'default' for fetch.proxy (admins only)",
+				handler : middlewareAdmin(handlerFeedProxy),
+				complete : completeFeedProxy,
+			},
+			{
+				name : "pause",
+				args : []string{"url"},
+				flags : []flagDef{
+					{name : "reason", value : "text", usage : "Why the feed is paused, shown by the feeds command"},
+				},
+				description : "Stop fetching a feed until it is resumed",
+				handler : middlewareLoggedIn(handlerFeedPause),
+				complete : completeFeedURLs,
+			},
+			{
+				name : "resume",
+				args : []string{"url"},
+				description : "Fetch a paused feed again",
+				handler : middlewareLoggedIn(handlerFeedResume),
+				complete : completeFeedURLs,
+			},
+			{
+				name : "auth",
+				description : "Set credentials sent when fetching a private feed",
+				subcommands : []commandDef{
+					{
+						name : "basic",
+						args : []string{"url", "username"},
+						description : "Use HTTP basic auth, asks for the password",
+						handler : middlewareLoggedIn(handlerFeedAuthBasic),
+						complete : completeFeedURLs,
+					},
+					{
+						name : "bearer",
+						args : []string{"url"},
+						description : "Send a bearer token, asks for the token",
+						handler : middlewareLoggedIn(handlerFeedAuthBearer),
+						complete : completeFeedURLs,
+					},
+					{
+						name : "cookie",
+						args : []string{"url"},
+						description : "Send a Cookie header, asks for its value",
+						handler : middlewareLoggedIn(handlerFeedAuthCookie),
+						complete : completeFeedURLs,
+					},
+					{
+						name : "header",
+						args : []string{"url", "name"},
+						description : "Send a custom header, asks for its value (empty removes it)",
+						handler : middlewareLoggedIn(handlerFeedAuthHeader),
+						complete : completeFeedURLs,
+					},
+					{
+						name : "show",
+						args : []string{"url"},
+						description : "List the credentials a feed has, without their values",
+						handler : middlewareLoggedIn(handlerFeedAuthShow),
+						complete : completeFeedURLs,
+					},
+					{
+						name : "clear",
+						args : []string{"url"},
+						description : "Remove all credentials from a feed",
+						handler : middlewareLoggedIn(handlerFeedAuthClear),
+						complete : completeFeedURLs,
+					},
+				},
+			},
+		},
+	})
+	commands.register(commandDef{
+		name : "follow",
+		args : []string{"url"},
+		description : "Follow a feed by URL",
+		handler : middlewareLoggedIn(handlerFollow),
+		complete : completeFeedURLs,
+	})
+	commands.register(commandDef{
+		name : "unfollow",
+		args : []string{"url"},
+		description : "Unfollow a feed by URL",
+		handler : middlewareLoggedIn(handlerUnfollow),
+		complete : completeFollowedFeedURLs,
+	})
+	commands.register(commandDef{
+		name : "folder",
+		args : []string{"url", "[name]"},
+		description : "Put a followed feed in a folder for tui, or take it out of its folder without a name",
+		handler : middlewareLoggedIn(handlerFolder),
+		complete : completeFolders,
+	})
+	commands.register(commandDef{
+		name : "following",
+		description : "Show feeds followed by the current user",
+		handler : middlewareLoggedIn(handlerFollowing),
+	})
+	commands.register(commandDef{
+		name : "agg",
+		args : []string{"[duration]"},
+		flags : []flagDef{
+			{name : "once", usage : "Fetch every feed once and exit, failing if any feed failed"},
+			{name : "pause-after", value : "n", usage : "Pause a feed after this many 404/410 responses in a row, 0 to never pause (default 5)"},
+		},
+		description : "Continuously fetch feeds every given duration (e.g. 1m)",
+		handler : handlerAgg,
+	})
+	commands.register(commandDef{
+		name : "fetch",
+		args : []string{"url"},
+		description : "Fetch one feed now and list the new posts",
+		handler : handlerFetch,
+		complete : completeFeedURLs,
+	})
+	commands.register(commandDef{
+		name : "browse",
+		args : []string{"[limit]"},
+		flags : []flagDef{
+			{name : "full", usage : "Print each post with its content as text instead of a table"},
+		},
+		description : "Show recent posts for followed feeds (default limit = 2)",
+		handler : middlewareLoggedIn(handlerBrowse),
+	})
+	commands.register(commandDef{
+		name : "show",
+		args : []string{"post"},
+		description : "Print a post by URL or ID as text, with links as footnotes, and mark it read",
+		handler : middlewareLoggedIn(handlerShow),
+	})
+	commands.register(commandDef{
+		name : "tui",
+		flags : []flagDef{
+			{name : "refresh", value : "duration", usage : "How often to reload posts (default 15s)"},
+			{name : "limit", value : "n", usage : "Maximum number of posts to load (default 500)"},
+		},
+		description : "Browse followed feeds and posts in an interactive terminal reader",
+		handler : middlewareLoggedIn(handlerTUI),
+	})
+	commands.register(commandDef{
+		name : "shell",
+		description : "Start an interactive shell that keeps one session open",
+		handler : commands.handlerShell,
+	})
+	commands.register(commandDef{
+		name : "config",
+		description : "Show and change settings in the config file",
+		subcommands : []commandDef{
+			{
+				name : "get",
+				args : []string{"[key]"},
+				description : "Print one setting, or all settings with their source",
+				handler : handlerConfigGet,
+				complete : completeConfigKeys,
+			},
+			{
+				name : "set",
+				args : []string{"key", "value"},
+				description : "Save a setting to the config file",
+				handler : handlerConfigSet,
+				complete : completeConfigKeys,
+			},
+			{
+				name : "path",
+				description : "Print the location of the config file",
+				handler : handlerConfigPath,
+			},
+			{
+				name : "validate",
+				description : "Check the config file and database connection",
+				handler : handlerConfigValidate,
+			},
+		},
+	})
+	commands.register(commandDef{
+		name : "profile",
+		description : "Manage named config profiles (database and current user)",
+		subcommands : []commandDef{
+			{
+				name : "list",
+				description : "List profiles and mark the active one",
+				handler : handlerProfileList,
+			},
+			{
+				name : "use",
+				args : []string{"name"},
+				description : "Switch to a profile and save it as current",
+				handler : handlerProfileUse,
+				complete : completeProfiles,
+			},
+			{
+				name : "add",
+				args : []string{"name", "db_url"},
+				description : "Add a profile with its own database URL",
+				handler : handlerProfileAdd,
+			},
+			{
+				name : "remove",
+				args : []string{"name"},
+				description : "Remove a profile",
+				handler : handlerProfileRemove,
+				complete : completeProfiles,
+			},
+		},
+	})
+	commands.register(commandDef{
+		name : "completion",
+		args : []string{"shell"},
+		description : "Print a completion script for bash, zsh or fish",
+		handler : handlerCompletion,
+		complete : completeShells,
+		offline : true,
+	})
+	commands.register(commandDef{
+		name : "__complete",
+		args : []string{"[words...]"},
+		hidden : true,
+		rawArgs : true,
+		offline : true,
+		handler : commands.handlerComplete,
+	})
 	args := os.Args
 	if len(args) < 2 {
-		err := fmt.Errorf("Not enough arguments")
-		fmt.Fprintln(os.Stderr, err)
+		commands.printHelp(os.Stderr)
 		os.Exit(1)
 	}
-	name := args[1]
-	cmdArgs := args[2:]
+	name, cmdArgs := commands.splitArgs(args[1:])
+	if name == "" {
+		name = "help"
+	}
 	cmd := command{
 		name: name,
 		args: cmdArgs,
 	}
-	err = commands.run(&s, cmd)
+
+	var s state
+	if !commands.offline(cmd) {
+		conf, err := config.Read()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		} 
+		s.cfg = &conf
+
+		err = s.connect()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	err := commands.run(&s, cmd)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
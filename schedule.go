@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/andrei-himself/gator/internal/database"
+	"github.com/andrei-himself/gator/internal/rss"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultFetchInterval = time.Hour
+	minFetchInterval = 10 * time.Minute
+	maxFetchInterval = 24 * time.Hour
+)
+
+// fetchInterval returns how long to wait before fetching a feed again. An
+// interval set with 'feed interval' wins; otherwise it adapts to how often
+// the feed publishes, but never goes below what the feed itself asks for.
+// feed may be nil when the fetch failed.
+func fetchInterval(dbFeed database.Feed, feed *rss.RSSFeed, published []time.Time) time.Duration {
+	if dbFeed.FetchInterval.Valid {
+		return time.Duration(dbFeed.FetchInterval.Int32) * time.Second
+	}
+	interval := adaptiveInterval(published)
+	if feed != nil {
+		interval = max(interval, feed.MinInterval())
+	}
+	return interval
+}
+
+// adaptiveInterval is half the median gap between the latest posts, so a
+// feed is fetched about twice for every post it publishes.
+func adaptiveInterval(published []time.Time) time.Duration {
+	if len(published) < 2 {
+		return defaultFetchInterval
+	}
+	latest := slices.Clone(published)
+	slices.SortFunc(latest, func(a, b time.Time) int {
+		return b.Compare(a)
+	})
+	latest = latest[:min(len(latest), 10)]
+	var gaps []time.Duration
+	for i := 1; i < len(latest); i++ {
+		gaps = append(gaps, latest[i-1].Sub(latest[i]))
+	}
+	slices.Sort(gaps)
+	interval := gaps[len(gaps)/2] / 2
+	return min(max(interval, minFetchInterval), maxFetchInterval)
+}
+
+// nextFetchAt returns now plus interval, moved past the hours and days the
+// feed asks to be skipped.
+func nextFetchAt(now time.Time, interval time.Duration, feed *rss.RSSFeed) time.Time {
+	next := now.Add(interval)
+	for i := 0; feed != nil && i < 7*24 && feed.Skipped(next); i++ {
+		next = next.Truncate(time.Hour).Add(time.Hour)
+	}
+	return next
+}
+
+func scheduleFeed(s *state, id uuid.UUID, next time.Time) error {
+	return s.db.ScheduleFeed(context.Background(), database.ScheduleFeedParams{
+		ID : id,
+		NextFetchAt : sql.NullTime{Time: next, Valid: true},
+	})
+}
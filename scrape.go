@@ -0,0 +1,452 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrei-himself/gator/internal/canonical"
+	"github.com/andrei-himself/gator/internal/config"
+	"github.com/andrei-himself/gator/internal/database"
+	"github.com/andrei-himself/gator/internal/render"
+	"github.com/andrei-himself/gator/internal/output"
+	"github.com/andrei-himself/gator/internal/rss"
+	"github.com/google/uuid"
+)
+
+// defaultPauseAfter is how many 404/410 responses in a row pause a feed.
+const defaultPauseAfter = 5
+
+func handlerAgg(s *state, cmd command) error {
+	if err := configureFetch(s.cfg); err != nil {
+		return err
+	}
+	pauseAfter := defaultPauseAfter
+	if cmd.hasFlag("pause-after") {
+		var err error
+		pauseAfter, err = strconv.Atoi(cmd.flag("pause-after"))
+		if err != nil || pauseAfter < 0 {
+			return fmt.Errorf("--pause-after expects a number of failures, got '%s'", cmd.flag("pause-after"))
+		}
+	}
+	if cmd.boolFlag("once") {
+		if len(cmd.args) > 0 {
+			return fmt.Errorf("agg --once doesn't take a duration")
+		}
+		return scrapeAll(s, pauseAfter)
+	}
+	if len(cmd.args) == 0 {
+		return fmt.Errorf("agg command expects a duration (e.g. 1m), or --once")
+	}
+	duration, err := time.ParseDuration(cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Collecting feeds every %v\n", duration)
+	ticker := time.NewTicker(duration)
+	for ; ; <-ticker.C {
+		if err := scrapeFeeds(s, pauseAfter); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+func handlerFetch(s *state, cmd command) error {
+	if err := configureFetch(s.cfg); err != nil {
+		return err
+	}
+	url := cmd.args[0]
+	feed, err := getFeedByURL(s, url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("feed '%s' doesn't exist in the database", url)
+	} else if err != nil {
+		return err
+	}
+	posts, err := fetchFeed(s, feed, defaultPauseAfter)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Fetched '%s': %d new post(s)\n", feed.Name, len(posts))
+	if feed.PausedAt.Valid {
+		fmt.Fprintf(os.Stderr, "Note: the feed is paused, run 'gator feed resume %s' to fetch it regularly again\n", feed.Url)
+	}
+	table := output.Table{
+		Columns : []output.Column{
+			{Name : "published_at"},
+			{Name : "title", MaxWidth : 60},
+			{Name : "url"},
+		},
+	}
+	for _, v := range posts {
+		table.Add(v.PublishedAt, nullString(v.Title), v.Url)
+	}
+	return printTable(cmd, table)
+}
+
+// fetchOptions reads the fetch client settings from the config file.
+func fetchOptions(cfg *config.Config) (rss.Options, error) {
+	opts := rss.Options{
+		UserAgent : cfg.Fetch.UserAgent,
+		Allow : config.AllowList(cfg.Fetch.Allow),
+		Proxy : cfg.Fetch.Proxy,
+		CAFile : cfg.Fetch.CAFile,
+		ClientCert : cfg.Fetch.ClientCert,
+		ClientKey : cfg.Fetch.ClientKey,
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = fmt.Sprintf("gator/%s (+https://github.com/andrei-himself/gator)", version)
+	}
+	var err error
+	if cfg.Fetch.Timeout != "" {
+		if opts.Timeout, err = time.ParseDuration(cfg.Fetch.Timeout); err != nil {
+			return rss.Options{}, fmt.Errorf("fetch.timeout: %w", err)
+		}
+	}
+	if cfg.Fetch.ConnectTimeout != "" {
+		if opts.ConnectTimeout, err = time.ParseDuration(cfg.Fetch.ConnectTimeout); err != nil {
+			return rss.Options{}, fmt.Errorf("fetch.connect_timeout: %w", err)
+		}
+	}
+	if cfg.Fetch.MaxBodySize != "" {
+		if opts.MaxBodySize, err = config.ParseSize(cfg.Fetch.MaxBodySize); err != nil {
+			return rss.Options{}, fmt.Errorf("fetch.max_body_size: %w", err)
+		}
+	}
+	return opts, nil
+}
+
+func configureFetch(cfg *config.Config) error {
+	opts, err := fetchOptions(cfg)
+	if err != nil {
+		return err
+	}
+	return rss.Configure(opts)
+}
+
+// checkFeedURL rejects URLs the fetcher would refuse, such as ones on a
+// private network, before they are stored.
+func checkFeedURL(s *state, url string) error {
+	if err := configureFetch(s.cfg); err != nil {
+		return err
+	}
+	return rss.CheckURL(context.Background(), url)
+}
+
+// scrapeFeeds fetches the feed that is most overdue, if any.
+func scrapeFeeds(s *state, pauseAfter int) error {
+	nextFeedToFetch, err := s.db.GetNextFeedToFetch(context.Background(), time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		// nothing is due, or every feed is paused
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, err = fetchFeed(s, nextFeedToFetch, pauseAfter)
+	return err
+}
+
+// scrapeAll fetches every due feed once, reporting each result, and fails
+// if any of them failed.
+func scrapeAll(s *state, pauseAfter int) error {
+	feeds, err := s.db.GetDueFeeds(context.Background(), time.Now())
+	if err != nil {
+		return err
+	}
+	fetched, failed := 0, 0
+	for _, feed := range feeds {
+		fetched++
+		posts, err := fetchFeed(s, feed, pauseAfter)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", feed.Name, err)
+			continue
+		}
+		fmt.Printf("%s: %d new post(s)\n", feed.Name, len(posts))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d feeds failed", failed, fetched)
+	}
+	return nil
+}
+
+// fetchSettings returns the per-feed proxy, as a context for FetchFeed, and
+// credentials of dbFeed.
+func fetchSettings(s *state, dbFeed database.Feed) (context.Context, *rss.Credentials, error) {
+	ctx := context.Background()
+	if dbFeed.Proxy.Valid {
+		var err error
+		if ctx, err = rss.WithProxy(ctx, dbFeed.Proxy.String); err != nil {
+			return nil, nil, fmt.Errorf("feed '%s' has an invalid proxy: %w", dbFeed.Name, err)
+		}
+	}
+	creds, err := loadCredentials(s, dbFeed)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ctx, creds, nil
+}
+
+// fetchFeed fetches feed, stores its posts and returns the ones that weren't
+// in the database yet.
+func fetchFeed(s *state, dbFeed database.Feed, pauseAfter int) ([]database.Post, error) {
+	markParams := database.MarkFeedFetchedParams{
+		ID : dbFeed.ID,
+		LastFetchedAt : sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	err := s.db.MarkFeedFetched(context.Background(), markParams)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ctx, creds, err := fetchSettings(s, dbFeed)
+	if err != nil {
+		// not the feed's fault, try again later without counting a failure
+		next := nextFetchAt(now, fetchInterval(dbFeed, nil, nil), nil)
+		if err := scheduleFeed(s, dbFeed.ID, next); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+	feed, err := rss.FetchFeed(ctx, dbFeed.Url, creds)
+	if err != nil {
+		next, ok := retryAt(err, now)
+		if !ok {
+			next = nextFetchAt(now, fetchInterval(dbFeed, nil, nil), nil)
+		}
+		if err := scheduleFeed(s, dbFeed.ID, next); err != nil {
+			return nil, err
+		}
+		return nil, recordFetchFailure(s, dbFeed, err, pauseAfter)
+	}
+	if feed.MovedTo != "" {
+		movedTo, err := canonicalURL(s, feed.MovedTo)
+		if err != nil {
+			return nil, err
+		}
+		if movedTo != dbFeed.Url && !creds.Empty() && !credentialsStay(dbFeed.Url, movedTo) {
+			// the credentials were only approved for the old host; the
+			// redirect is fetched without them
+			fmt.Printf("Feed '%s' moved permanently to %s on another host or to plain http but has credentials, so the move isn't recorded. Run 'gator feed set-url %s %s' to move it, which clears them unless you add --keep-credentials\n", dbFeed.Name, movedTo, dbFeed.Url, movedTo)
+		} else if movedTo != dbFeed.Url {
+			target, merged, err := moveFeed(s, dbFeed, movedTo)
+			if err != nil {
+				return nil, err
+			}
+			if merged {
+				fmt.Printf("Feed '%s' moved permanently to %s and was merged into '%s'\n", dbFeed.Name, movedTo, target.Name)
+			} else {
+				fmt.Printf("Feed '%s' moved permanently from %s to %s\n", dbFeed.Name, dbFeed.Url, movedTo)
+			}
+			dbFeed = target
+		}
+	}
+	if dbFeed.FailureCount > 0 {
+		err = s.db.ResetFeedFailures(context.Background(), dbFeed.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var inserted []database.Post
+	var published []time.Time
+	for _, v := range feed.Channel.Item {
+		t, ok := parsePubDate(v.PubDate)
+		if !ok {
+			fmt.Println("no valid publish date for post:\n", v)
+			continue
+		}
+		published = append(published, t)
+
+		// posts are told apart by URL, so items without one can't be stored
+		link := v.URL()
+		if link == "" {
+			fmt.Printf("%s: skipping post '%s' without a link\n", dbFeed.Name, v.Title)
+			continue
+		}
+		postURL, err := canonicalURL(s, link)
+		if err != nil {
+			postURL = link
+		}
+		postParams := database.CreatePostParams{
+			ID : uuid.New(),
+			CreatedAt : time.Now(),
+			UpdatedAt : time.Now(),
+			Title : sql.NullString{String: v.Title, Valid: true},
+			Url : postURL,
+			Description : sql.NullString{String: render.Sanitize(v.Description), Valid: true},
+			PublishedAt : t,
+		}
+		post, isNew, err := storePost(s, dbFeed.ID, postParams)
+		if err != nil {
+			return inserted, err
+		}
+		if isNew {
+			inserted = append(inserted, post)
+		}
+	}
+	next := nextFetchAt(now, fetchInterval(dbFeed, feed, published), feed)
+	return inserted, scheduleFeed(s, dbFeed.ID, next)
+}
+
+// storePost saves a post of feedID. A post that is already stored, maybe
+// for another feed, is only linked to feedID; isNew reports whether the post
+// was created.
+func storePost(s *state, feedID uuid.UUID, params database.CreatePostParams) (database.Post, bool, error) {
+	ctx := context.Background()
+	// the same post may be linked as http and https, or with and without a
+	// trailing slash
+	variants := canonical.Variants(params.Url)
+	post, err := s.db.GetPostByURLs(ctx, variants)
+	isNew := false
+	if errors.Is(err, sql.ErrNoRows) {
+		post, err = s.db.CreatePost(ctx, params)
+		isNew = err == nil
+		if errors.Is(err, sql.ErrNoRows) {
+			// stored by another fetch since the lookup
+			post, err = s.db.GetPostByURLs(ctx, variants)
+		}
+	}
+	if err != nil {
+		return post, false, err
+	}
+	err = s.db.AddFeedPost(ctx, database.AddFeedPostParams{
+		FeedID : feedID,
+		PostID : post.ID,
+		CreatedAt : time.Now(),
+	})
+	return post, isNew, err
+}
+
+// sameHost reports whether two URLs have the same host and port.
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Host, ub.Host)
+}
+
+// moveFeed points dbFeed at a new URL, such as the one it was permanently
+// redirected to. If another feed already has that URL, dbFeed is merged into
+// it: its follows and posts move over and dbFeed is deleted. It returns the
+// feed that now has the URL and whether it was merged.
+func moveFeed(s *state, dbFeed database.Feed, url string) (database.Feed, bool, error) {
+	ctx := context.Background()
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return dbFeed, false, err
+	}
+	defer tx.Rollback()
+	q := s.db.WithTx(tx)
+
+	// a move to another form of the same URL, e.g. http to https, finds the
+	// feed itself
+	target, err := q.GetFeedByURLs(ctx, canonical.Variants(url))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && target.ID == dbFeed.ID) {
+		err = q.SetFeedURL(ctx, database.SetFeedURLParams{
+			ID : dbFeed.ID,
+			Url : url,
+			UpdatedAt : time.Now(),
+		})
+		if err != nil {
+			return dbFeed, false, err
+		}
+		if err := tx.Commit(); err != nil {
+			return dbFeed, false, err
+		}
+		dbFeed.Url = url
+		return dbFeed, false, nil
+	} else if err != nil {
+		return dbFeed, false, err
+	}
+
+	err = q.MoveFeedFollows(ctx, database.MoveFeedFollowsParams{ToFeedID : target.ID, FromFeedID : dbFeed.ID})
+	if err != nil {
+		return dbFeed, false, err
+	}
+	err = q.MoveFeedPosts(ctx, database.MoveFeedPostsParams{ToFeedID : target.ID, FromFeedID : dbFeed.ID})
+	if err != nil {
+		return dbFeed, false, err
+	}
+	if err := q.DeleteFeed(ctx, dbFeed.ID); err != nil {
+		return dbFeed, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return dbFeed, false, err
+	}
+	return target, true, nil
+}
+
+// recordFetchFailure counts consecutive permanent failures of a feed and
+// pauses it once there are pauseAfter of them in a row (zero never pauses).
+// A feed answering 410 Gone is paused straight away.
+func recordFetchFailure(s *state, feed database.Feed, fetchErr error, pauseAfter int) error {
+	var statusErr *rss.StatusError
+	if !errors.As(fetchErr, &statusErr) || !statusErr.Permanent() {
+		return fetchErr
+	}
+	failures, err := s.db.RecordFeedFailure(context.Background(), feed.ID)
+	if err != nil {
+		return err
+	}
+	if feed.PausedAt.Valid {
+		return fetchErr
+	}
+	reason := fmt.Sprintf("%s %d times in a row", statusErr.Status, failures)
+	if statusErr.Gone() {
+		reason = statusErr.Status + ", the feed was removed"
+	} else if pauseAfter == 0 || int(failures) < pauseAfter {
+		return fetchErr
+	}
+	err = s.db.PauseFeed(context.Background(), database.PauseFeedParams{
+		ID : feed.ID,
+		PausedAt : sql.NullTime{Time: time.Now(), Valid: true},
+		PauseReason : sql.NullString{String: reason, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w, feed '%s' paused: %s", fetchErr, feed.Name, reason)
+}
+
+// retryAt returns when a fetch that failed with err may be tried again, if
+// the server or the host limiter said so.
+func retryAt(err error, now time.Time) (time.Time, bool) {
+	var statusErr *rss.StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return now.Add(statusErr.RetryAfter), true
+	}
+	var blockedErr *rss.HostBlockedError
+	if errors.As(err, &blockedErr) {
+		return blockedErr.Until, true
+	}
+	return time.Time{}, false
+}
+
+func parsePubDate(s string) (time.Time, bool) {
+    layouts := []string{
+        time.RFC1123Z,
+        time.RFC1123,
+        time.RFC822Z,
+        time.RFC822,
+        time.RFC3339,
+    }
+    for _, layout := range layouts {
+        if t, err := time.Parse(layout, s); err == nil {
+            return t, true
+        }
+    }
+    return time.Time{}, false
+}
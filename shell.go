@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andrei-himself/gator/internal/config"
+	"golang.org/x/term"
+)
+
+const maxShellHistory = 1000
+
+var shellBuiltins = []string{"exit", "quit", "su"}
+
+type shell struct {
+	c    *commands
+	s    *state
+	term *term.Terminal
+}
+
+func (c *commands) handlerShell(s *state, cmd command) error {
+	sh := &shell{c : c, s : s}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		// read commands from a pipe or file, one per line; passwords asked
+		// for by commands come from the following lines
+		for {
+			line, err := stdinReader.ReadString('\n')
+			if line != "" && sh.exec(strings.TrimRight(line, "\r\n")) {
+				return nil
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	history := loadShellHistory()
+	sh.term = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	sh.term.History = history
+	sh.term.AutoCompleteCallback = sh.autoComplete
+
+	fmt.Println("gator shell - type 'help' for commands, 'exit' to quit")
+	for {
+		sh.term.SetPrompt(sh.prompt())
+		line, err := sh.readLine(fd)
+		if errors.Is(err, io.EOF) {
+			fmt.Println()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if sh.exec(line) {
+			return nil
+		}
+	}
+}
+
+// readLine puts the terminal in raw mode only while a line is being edited,
+// so commands print normally.
+func (sh *shell) readLine(fd int) (string, error) {
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(fd, oldState)
+	if w, h, err := term.GetSize(fd); err == nil && w > 0 {
+		sh.term.SetSize(w, h)
+	}
+	return sh.term.ReadLine()
+}
+
+func (sh *shell) prompt() string {
+	prompt := "gator"
+	if profile := sh.s.cfg.ActiveProfile(); profile != config.DefaultProfile {
+		prompt += " [" + profile + "]"
+	}
+	if name := sh.s.currentUserName(); name != "" {
+		prompt += " (" + name + ")"
+	}
+	return prompt + "> "
+}
+
+// exec runs one input line and reports whether the shell should exit.
+func (sh *shell) exec(line string) bool {
+	words, err := splitLine(line)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	if len(words) == 0 {
+		return false
+	}
+	switch words[0] {
+	case "exit", "quit":
+		return true
+	case "shell":
+		fmt.Fprintln(os.Stderr, "already in a gator shell")
+		return false
+	case "su":
+		if err := sh.switchUser(words[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return false
+	}
+	name, args := sh.c.splitArgs(words)
+	if name == "" {
+		name = "help"
+	}
+	if err := sh.c.run(sh.s, command{name: name, args: args}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return false
+}
+
+// switchUser changes the current user for this session only, leaving the
+// config file untouched. Password protected users are asked for their
+// password.
+func (sh *shell) switchUser(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("su command expects username as an argument")
+	}
+	user, err := sh.s.db.GetUser(context.Background(), args[0])
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("username doesn't exist in the database")
+	} else if err != nil {
+		return err
+	}
+	if err := verifyPassword(user, "Password: "); err != nil {
+		return err
+	}
+	sh.s.sessionUser = args[0]
+	return nil
+}
+
+func (sh *shell) autoComplete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	head, tail := line[:pos], line[pos:]
+	words, err := splitLine(head)
+	if err != nil {
+		words = strings.Fields(head)
+	}
+	if len(words) == 0 || strings.HasSuffix(head, " ") {
+		words = append(words, "")
+	}
+	partial := words[len(words)-1]
+
+	var candidates []string
+	switch {
+	case len(words) == 1:
+		candidates = filterPrefix(append(sh.c.visibleNames(), shellBuiltins...), partial)
+	case words[0] == "su":
+		candidates = filterPrefix(completeUsernames(sh.s, words[1:len(words)-1]), partial)
+	default:
+		candidates = sh.c.completions(sh.s, words)
+	}
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+
+	completion := candidates[0] + " "
+	if len(candidates) > 1 {
+		completion = commonPrefix(candidates)
+		if completion == partial {
+			fmt.Fprintln(sh.term, strings.Join(candidates, "  "))
+			return "", 0, false
+		}
+	}
+	newHead := head[:len(head)-len(partial)] + completion
+	return newHead + tail, len(newHead), true
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// splitLine splits a shell line into words, honouring single and double
+// quotes and backslash escapes.
+func splitLine(line string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+// shellHistory keeps shell input across sessions in ~/.gator_history.
+type shellHistory struct {
+	entries []string
+	path    string
+}
+
+func loadShellHistory() *shellHistory {
+	h := &shellHistory{}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return h
+	}
+	h.path = filepath.Join(home, ".gator_history")
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return h
+	}
+	// drop secrets saved before they were kept out of the history
+	dropped := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		if secretEntry(line) {
+			dropped = true
+			continue
+		}
+		h.entries = append(h.entries, line)
+	}
+	if len(h.entries) > maxShellHistory {
+		h.entries = h.entries[len(h.entries)-maxShellHistory:]
+	}
+	if dropped {
+		os.WriteFile(h.path, []byte(strings.Join(h.entries, "\n")+"\n"), 0o600)
+	}
+	return h
+}
+
+func (h *shellHistory) Add(entry string) {
+	if entry == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry) {
+		return
+	}
+	if secretEntry(entry) {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > maxShellHistory {
+		h.entries = h.entries[1:]
+	}
+	if h.path == "" {
+		return
+	}
+	file, err := os.OpenFile(h.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, entry)
+}
+
+// secretEntry reports whether a shell line may hold a password and should be
+// kept out of the history: a database URL given to profile add or config set,
+// or any URL with a password in it.
+func secretEntry(entry string) bool {
+	words, err := splitLine(entry)
+	if err != nil {
+		words = strings.Fields(entry)
+	}
+	for i, word := range words {
+		rest := words[i:]
+		if len(rest) >= 2 && rest[0] == "profile" && rest[1] == "add" {
+			return true
+		}
+		if len(rest) >= 3 && rest[0] == "config" && rest[1] == "set" && rest[2] == "db_url" {
+			return true
+		}
+		if u, err := url.Parse(word); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (h *shellHistory) Len() int {
+	return len(h.entries)
+}
+
+func (h *shellHistory) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
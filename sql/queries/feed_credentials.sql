@@ -0,0 +1,19 @@
+-- name: SetFeedCredentials :exec
+INSERT INTO feed_credentials (feed_id, encrypted, created_at, updated_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $3
+)
+ON CONFLICT (feed_id) DO UPDATE
+SET encrypted = EXCLUDED.encrypted,
+    updated_at = EXCLUDED.updated_at;
+
+-- name: GetFeedCredentials :one
+SELECT * FROM feed_credentials
+WHERE feed_id = $1;
+
+-- name: DeleteFeedCredentials :exec
+DELETE FROM feed_credentials
+WHERE feed_id = $1;
\ No newline at end of file
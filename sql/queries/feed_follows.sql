@@ -21,6 +21,7 @@ INNER JOIN users ON users.id = inserted_feed_follow.user_id;
 -- name: GetFeedFollowsForUser :many
 SELECT feed_follows.*,
 feeds.name AS feed_name,
+feeds.url AS feed_url,
 users.name AS user_name
 FROM feed_follows
 INNER JOIN feeds ON feeds.id = feed_follows.feed_id
@@ -33,4 +34,19 @@ WHERE user_id = $1
 AND feed_id = $2;
 
 -- name: DeleteFeedFollows :exec
-DELETE FROM feed_follows;
\ No newline at end of file
+DELETE FROM feed_follows;
+
+-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = sqlc.arg(to_feed_id)
+WHERE feed_id = sqlc.arg(from_feed_id)
+AND user_id NOT IN (
+    SELECT user_id FROM feed_follows
+    WHERE feed_id = sqlc.arg(to_feed_id)
+);
+
+-- name: SetFeedFollowFolder :execrows
+UPDATE feed_follows
+SET folder = $3, updated_at = $4
+WHERE user_id = $1
+AND feed_id = $2;
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- name: AddFeedPost :exec
+INSERT INTO feed_posts (feed_id, post_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (feed_id, post_id) DO NOTHING;
+
+-- name: MoveFeedPosts :exec
+INSERT INTO feed_posts (feed_id, post_id, created_at)
+SELECT sqlc.arg(to_feed_id), post_id, created_at
+FROM feed_posts
+WHERE feed_id = sqlc.arg(from_feed_id)
+ON CONFLICT (feed_id, post_id) DO NOTHING;
+
+-- name: MovePostFeeds :exec
+INSERT INTO feed_posts (feed_id, post_id, created_at)
+SELECT feed_id, sqlc.arg(to_post_id), created_at
+FROM feed_posts
+WHERE post_id = sqlc.arg(from_post_id)
+ON CONFLICT (feed_id, post_id) DO NOTHING;
\ No newline at end of file
@@ -14,6 +14,35 @@ RETURNING *;
 -- name: DeleteFeeds :exec
 DELETE FROM feeds;
 
+-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1;
+
+-- name: RenameFeed :exec
+UPDATE feeds
+SET name = $2,
+    updated_at = $3
+WHERE id = $1;
+
+-- name: SetFeedURL :exec
+UPDATE feeds
+SET url = $2,
+    next_fetch_at = NULL,
+    updated_at = $3
+WHERE id = $1;
+
+-- name: SetFeedOwner :exec
+UPDATE feeds
+SET user_id = $2,
+    updated_at = $3
+WHERE id = $1;
+
+-- name: SetFeedProxy :exec
+UPDATE feeds
+SET proxy = $2,
+    updated_at = $3
+WHERE id = $1;
+
 -- name: GetFeeds :many
 SELECT * FROM feeds;
 
@@ -21,6 +50,12 @@ SELECT * FROM feeds;
 SELECT * FROM feeds
 WHERE URL = $1; 
 
+-- name: GetFeedByURLs :one
+SELECT * FROM feeds
+WHERE url = ANY(sqlc.arg(urls)::text[])
+ORDER BY array_position(sqlc.arg(urls)::text[], url)
+LIMIT 1;
+
 -- name: MarkFeedFetched :exec
 UPDATE feeds
 SET last_fetched_at = $2,
@@ -29,5 +64,52 @@ WHERE id = $1;
 
 -- name: GetNextFeedToFetch :one
 SELECT * FROM feeds
-ORDER BY last_fetched_at NULLS FIRST
-FETCH FIRST 1 ROW ONLY;
\ No newline at end of file
+WHERE paused_at IS NULL
+AND (next_fetch_at IS NULL OR next_fetch_at <= sqlc.arg(now)::timestamp)
+ORDER BY next_fetch_at NULLS FIRST, last_fetched_at NULLS FIRST
+FETCH FIRST 1 ROW ONLY;
+
+-- name: GetDueFeeds :many
+SELECT * FROM feeds
+WHERE paused_at IS NULL
+AND (next_fetch_at IS NULL OR next_fetch_at <= sqlc.arg(now)::timestamp)
+ORDER BY next_fetch_at NULLS FIRST, last_fetched_at NULLS FIRST;
+
+-- name: PauseFeed :exec
+UPDATE feeds
+SET paused_at = $2,
+    pause_reason = $3,
+    updated_at = $2
+WHERE id = $1;
+
+-- name: ResumeFeed :exec
+UPDATE feeds
+SET paused_at = NULL,
+    pause_reason = NULL,
+    failure_count = 0,
+    next_fetch_at = NULL,
+    updated_at = $2
+WHERE id = $1;
+
+-- name: RecordFeedFailure :one
+UPDATE feeds
+SET failure_count = failure_count + 1
+WHERE id = $1
+RETURNING failure_count;
+
+-- name: ResetFeedFailures :exec
+UPDATE feeds
+SET failure_count = 0
+WHERE id = $1;
+
+-- name: ScheduleFeed :exec
+UPDATE feeds
+SET next_fetch_at = $2
+WHERE id = $1;
+
+-- name: SetFeedInterval :exec
+UPDATE feeds
+SET fetch_interval = $2,
+    next_fetch_at = $3,
+    updated_at = $4
+WHERE id = $1;
\ No newline at end of file
@@ -1,5 +1,5 @@
 -- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at)
 VALUES (
     $1,
     $2,
@@ -7,12 +7,102 @@ VALUES (
     $4,
     $5,
     $6,
-    $7,
-    $8
+    $7
 )
+ON CONFLICT (url) DO NOTHING
 RETURNING *;
 
--- name: GetPostsForUser :many
+-- name: DeleteOrphanPosts :exec
+DELETE FROM posts
+WHERE NOT EXISTS (
+    SELECT 1 FROM feed_posts
+    WHERE feed_posts.post_id = posts.id
+);
+
+-- name: DeletePost :exec
+DELETE FROM posts
+WHERE id = $1;
+
+-- name: GetPostByURLs :one
 SELECT * FROM posts
-ORDER BY published_at
-FETCH FIRST $1 ROW ONLY;
\ No newline at end of file
+WHERE url = ANY(sqlc.arg(urls)::text[])
+ORDER BY array_position(sqlc.arg(urls)::text[], url)
+LIMIT 1;
+
+-- name: GetPostByURLsForUser :one
+SELECT posts.*,
+string_agg(feeds.name, ', ' ORDER BY feeds.name)::text AS feed_names
+FROM posts
+INNER JOIN feed_posts ON feed_posts.post_id = posts.id
+INNER JOIN feeds ON feeds.id = feed_posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = feed_posts.feed_id
+WHERE posts.url = ANY(sqlc.arg(urls)::text[])
+AND feed_follows.user_id = sqlc.arg(user_id)
+GROUP BY posts.id
+ORDER BY array_position(sqlc.arg(urls)::text[], posts.url)
+LIMIT 1;
+
+-- name: GetPostForUser :one
+SELECT posts.*,
+string_agg(feeds.name, ', ' ORDER BY feeds.name)::text AS feed_names
+FROM posts
+INNER JOIN feed_posts ON feed_posts.post_id = posts.id
+INNER JOIN feeds ON feeds.id = feed_posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = feed_posts.feed_id
+WHERE posts.id = $1
+AND feed_follows.user_id = $2
+GROUP BY posts.id;
+
+-- name: GetPostURLs :many
+SELECT id, url FROM posts
+ORDER BY created_at;
+
+-- name: GetPostsForUser :many
+SELECT posts.*,
+string_agg(feeds.name, ', ' ORDER BY feeds.name)::text AS feed_names
+FROM posts
+INNER JOIN feed_posts ON feed_posts.post_id = posts.id
+INNER JOIN feeds ON feeds.id = feed_posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = feed_posts.feed_id
+WHERE feed_follows.user_id = $1
+GROUP BY posts.id
+ORDER BY posts.published_at DESC
+FETCH FIRST $2 ROWS ONLY;
+
+-- name: GetPostsWithReadState :many
+SELECT posts.*,
+string_agg(feeds.name, ', ' ORDER BY feeds.name)::text AS feed_names,
+array_agg(feeds.id ORDER BY feeds.name)::uuid[] AS feed_ids,
+(post_reads.post_id IS NOT NULL)::boolean AS is_read
+FROM posts
+INNER JOIN feed_posts ON feed_posts.post_id = posts.id
+INNER JOIN feeds ON feeds.id = feed_posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = feed_posts.feed_id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id
+AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+GROUP BY posts.id, post_reads.post_id
+ORDER BY posts.published_at DESC
+FETCH FIRST $2 ROWS ONLY;
+
+-- name: MarkPostRead :exec
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkPostUnread :exec
+DELETE FROM post_reads
+WHERE user_id = $1
+AND post_id = $2;
+
+-- name: MovePostReads :exec
+INSERT INTO post_reads (user_id, post_id, read_at)
+SELECT user_id, sqlc.arg(to_post_id), read_at
+FROM post_reads
+WHERE post_id = sqlc.arg(from_post_id)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: SetPostURL :exec
+UPDATE posts
+SET url = $2, updated_at = $3
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- name: CreateSession :exec
+INSERT INTO sessions (token_hash, user_id, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+);
+
+-- name: GetSession :one
+SELECT * FROM sessions
+WHERE token_hash = $1;
+
+-- name: DeleteSessionsForUser :exec
+DELETE FROM sessions
+WHERE user_id = $1;
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE post_reads (
+    user_id UUID NOT NULL,
+        CONSTRAINT fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    post_id UUID NOT NULL,
+        CONSTRAINT fk_post_id FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    read_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, post_id)
+);
+
+-- +goose Down
+DROP TABLE post_reads;
\ No newline at end of file
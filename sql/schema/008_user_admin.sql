@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- the oldest user administers an existing database
+UPDATE users
+SET is_admin = TRUE
+WHERE id = (SELECT id FROM users ORDER BY created_at LIMIT 1);
+
+-- deleting a user removes their feeds, which must take the posts along
+ALTER TABLE posts
+DROP CONSTRAINT fk_feed_id,
+ADD CONSTRAINT fk_feed_id FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE posts
+DROP CONSTRAINT fk_feed_id,
+ADD CONSTRAINT fk_feed_id FOREIGN KEY (feed_id) REFERENCES feeds(id);
+
+ALTER TABLE users
+DROP COLUMN is_admin;
\ No newline at end of file
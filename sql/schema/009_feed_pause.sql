@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN paused_at TIMESTAMP,
+ADD COLUMN pause_reason TEXT,
+ADD COLUMN failure_count INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN paused_at,
+DROP COLUMN pause_reason,
+DROP COLUMN failure_count;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE feed_credentials (
+    feed_id UUID PRIMARY KEY NOT NULL,
+        CONSTRAINT fk_feed_id FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+    encrypted BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE feed_credentials;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN proxy TEXT;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN proxy;
\ No newline at end of file
@@ -0,0 +1,38 @@
+-- +goose Up
+CREATE TABLE feed_posts (
+    feed_id UUID NOT NULL,
+        CONSTRAINT fk_feed_id FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+    post_id UUID NOT NULL,
+        CONSTRAINT fk_post_id FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (feed_id, post_id)
+);
+
+CREATE INDEX feed_posts_post_id_idx ON feed_posts (post_id);
+
+INSERT INTO feed_posts (feed_id, post_id, created_at)
+SELECT feed_id, id, created_at FROM posts;
+
+ALTER TABLE posts
+DROP COLUMN feed_id;
+
+-- +goose Down
+ALTER TABLE posts
+ADD COLUMN feed_id UUID;
+
+UPDATE posts
+SET feed_id = (
+    SELECT feed_id FROM feed_posts
+    WHERE feed_posts.post_id = posts.id
+    ORDER BY created_at
+    LIMIT 1
+);
+
+DELETE FROM posts
+WHERE feed_id IS NULL;
+
+ALTER TABLE posts
+ALTER COLUMN feed_id SET NOT NULL,
+ADD CONSTRAINT fk_feed_id FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE;
+
+DROP TABLE feed_posts;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- +goose Up
+-- items without a link were all stored as one post with an empty URL,
+-- holding the content of whichever came first
+DELETE FROM posts
+WHERE url = '';
+
+-- +goose Down
+SELECT 1;
\ No newline at end of file
@@ -0,0 +1,651 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/andrei-himself/gator/internal/database"
+	"github.com/andrei-himself/gator/internal/output"
+	"github.com/andrei-himself/gator/internal/render"
+	"github.com/google/uuid"
+	"golang.org/x/term"
+)
+
+const (
+	paneFeeds = iota
+	panePosts
+	paneBody
+)
+
+// tuiFeed is a row in the feeds pane: "All feeds", a feed, or a folder that
+// shows the posts of every feed in it.
+type tuiFeed struct {
+	key    string
+	ids    []uuid.UUID
+	name   string
+	folder bool
+	nested bool
+	unread int
+}
+
+// shows reports whether p belongs to one of the row's feeds. The "All feeds"
+// row has no ids and shows every post.
+func (f tuiFeed) shows(p database.GetPostsWithReadStateRow) bool {
+	if f.ids == nil {
+		return true
+	}
+	for _, id := range p.FeedIds {
+		if slices.Contains(f.ids, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// feedRows lists "All feeds", then the feeds outside any folder, then each
+// folder followed by its feeds, all sorted by name.
+func feedRows(follows []database.GetFeedFollowsForUserRow) []tuiFeed {
+	sorted := slices.Clone(follows)
+	slices.SortFunc(sorted, func(a, b database.GetFeedFollowsForUserRow) int {
+		return strings.Compare(strings.ToLower(a.FeedName), strings.ToLower(b.FeedName))
+	})
+	rows := []tuiFeed{{name : "All feeds"}}
+	folders := map[string][]tuiFeed{}
+	var names []string
+	for _, f := range sorted {
+		row := tuiFeed{key : "feed:" + f.FeedID.String(), ids : []uuid.UUID{f.FeedID}, name : f.FeedName}
+		if !f.Folder.Valid {
+			rows = append(rows, row)
+			continue
+		}
+		if _, ok := folders[f.Folder.String]; !ok {
+			names = append(names, f.Folder.String)
+		}
+		row.nested = true
+		folders[f.Folder.String] = append(folders[f.Folder.String], row)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		folder := tuiFeed{key : "folder:" + name, name : name, folder : true}
+		for _, f := range folders[name] {
+			folder.ids = append(folder.ids, f.ids...)
+		}
+		rows = append(rows, folder)
+		rows = append(rows, folders[name]...)
+	}
+	return rows
+}
+
+type tui struct {
+	s          *state
+	user       database.User
+	limit      int32
+	width      int
+	height     int
+	focus      int
+	feeds      []tuiFeed
+	feedCursor int
+	feedOffset int
+	allPosts   []database.GetPostsWithReadStateRow
+	posts      []database.GetPostsWithReadStateRow
+	postCursor int
+	postOffset int
+	bodyOffset int
+	status     string
+}
+
+func handlerTUI(s *state, cmd command, user database.User) error {
+	refresh := 15 * time.Second
+	if cmd.hasFlag("refresh") {
+		d, err := time.ParseDuration(cmd.flag("refresh"))
+		if err != nil {
+			return err
+		}
+		refresh = d
+	}
+	var limit int32
+	limit = 500
+	if cmd.hasFlag("limit") {
+		converted, err := strconv.ParseInt(cmd.flag("limit"), 10, 32)
+		if err != nil || converted < 1 {
+			return fmt.Errorf("tui limit must be a positive number, got '%s'", cmd.flag("limit"))
+		}
+		limit = int32(converted)
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("tui command requires an interactive terminal")
+	}
+
+	t := &tui{
+		s : s,
+		user : user,
+		limit : limit,
+	}
+	if err := t.load(); err != nil {
+		return err
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, oldState)
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	// stop the key reader on exit so it doesn't take the next keystroke
+	// from the shell the tui was started in
+	input, closable := openInput()
+	if closable {
+		defer input.Close()
+	}
+	done := make(chan struct{})
+	defer close(done)
+	keys := make(chan string)
+	go readKeys(input, keys, done)
+	refreshTicker := time.NewTicker(refresh)
+	defer refreshTicker.Stop()
+	resizeTicker := time.NewTicker(250 * time.Millisecond)
+	defer resizeTicker.Stop()
+
+	t.resize()
+	t.draw()
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok || t.handleKey(key) {
+				return nil
+			}
+		case <-refreshTicker.C:
+			t.refresh()
+		case <-resizeTicker.C:
+			if !t.resize() {
+				continue
+			}
+		}
+		t.draw()
+	}
+}
+
+// load re-reads the user's follows and posts, keeping the current selection
+// when the selected feed and post still exist.
+func (t *tui) load() error {
+	follows, err := t.s.db.GetFeedFollowsForUser(context.Background(), t.user.ID)
+	if err != nil {
+		return err
+	}
+	params := database.GetPostsWithReadStateParams{
+		UserID : t.user.ID,
+		Limit : t.limit,
+	}
+	posts, err := t.s.db.GetPostsWithReadState(context.Background(), params)
+	if err != nil {
+		return err
+	}
+
+	selectedFeed := ""
+	if t.feedCursor < len(t.feeds) {
+		selectedFeed = t.feeds[t.feedCursor].key
+	}
+	t.feeds = feedRows(follows)
+	t.feedCursor = 0
+	for i, f := range t.feeds {
+		if f.key == selectedFeed {
+			t.feedCursor = i
+		}
+	}
+	t.allPosts = posts
+	t.countUnread()
+	t.filter()
+	return nil
+}
+
+func (t *tui) refresh() {
+	before := len(t.allPosts)
+	if err := t.load(); err != nil {
+		t.status = "refresh failed: " + err.Error()
+		return
+	}
+	if n := len(t.allPosts) - before; n > 0 {
+		t.status = fmt.Sprintf("%d new posts", n)
+	}
+}
+
+func (t *tui) countUnread() {
+	for i := range t.feeds {
+		t.feeds[i].unread = 0
+		for _, p := range t.allPosts {
+			if !p.IsRead && t.feeds[i].shows(p) {
+				t.feeds[i].unread++
+			}
+		}
+	}
+}
+
+// filter narrows the post list to the selected feed or folder.
+func (t *tui) filter() {
+	var selectedPost uuid.UUID
+	if t.postCursor < len(t.posts) {
+		selectedPost = t.posts[t.postCursor].ID
+	}
+	feed := t.feeds[t.feedCursor]
+	t.posts = t.posts[:0:0]
+	t.postCursor = 0
+	for _, p := range t.allPosts {
+		if !feed.shows(p) {
+			continue
+		}
+		if p.ID == selectedPost {
+			t.postCursor = len(t.posts)
+		}
+		t.posts = append(t.posts, p)
+	}
+}
+
+func (t *tui) selectedPost() (*database.GetPostsWithReadStateRow, bool) {
+	if t.postCursor >= len(t.posts) {
+		return nil, false
+	}
+	return &t.posts[t.postCursor], true
+}
+
+func (t *tui) setRead(read bool) {
+	post, ok := t.selectedPost()
+	if !ok || post.IsRead == read {
+		return
+	}
+	var err error
+	if read {
+		err = t.s.db.MarkPostRead(context.Background(), database.MarkPostReadParams{
+			UserID : t.user.ID,
+			PostID : post.ID,
+			ReadAt : time.Now(),
+		})
+	} else {
+		err = t.s.db.MarkPostUnread(context.Background(), database.MarkPostUnreadParams{
+			UserID : t.user.ID,
+			PostID : post.ID,
+		})
+	}
+	if err != nil {
+		t.status = "could not update read state: " + err.Error()
+		return
+	}
+	post.IsRead = read
+	for i := range t.allPosts {
+		if t.allPosts[i].ID == post.ID {
+			t.allPosts[i].IsRead = read
+		}
+	}
+	t.countUnread()
+}
+
+// handleKey applies a key press and reports whether the TUI should exit.
+func (t *tui) handleKey(key string) bool {
+	t.status = ""
+	switch key {
+	case "q", "ctrl-c":
+		return true
+	case "tab", "right", "l":
+		if t.focus < paneBody {
+			t.focus++
+		}
+	case "shift-tab", "left", "h":
+		if t.focus > paneFeeds {
+			t.focus--
+		}
+	case "up", "k":
+		t.move(-1)
+	case "down", "j":
+		t.move(1)
+	case "pgup":
+		t.move(-t.paneHeight())
+	case "pgdn", " ":
+		t.move(t.paneHeight())
+	case "home", "g":
+		t.move(-1 << 30)
+	case "end", "G":
+		t.move(1 << 30)
+	case "enter":
+		if t.focus == paneFeeds {
+			t.focus = panePosts
+		} else if _, ok := t.selectedPost(); ok {
+			t.focus = paneBody
+			t.setRead(true)
+		}
+	case "m":
+		if post, ok := t.selectedPost(); ok {
+			t.setRead(!post.IsRead)
+		}
+	case "o":
+		if post, ok := t.selectedPost(); ok {
+			if err := openBrowser(post.Url); err != nil {
+				t.status = "could not open browser: " + err.Error()
+			} else {
+				t.status = "opened " + post.Url
+				t.setRead(true)
+			}
+		}
+	case "r":
+		t.refresh()
+		if t.status == "" {
+			t.status = "refreshed"
+		}
+	}
+	return false
+}
+
+func (t *tui) move(delta int) {
+	clamp := func(v, n int) int {
+		if v >= n {
+			v = n - 1
+		}
+		if v < 0 {
+			v = 0
+		}
+		return v
+	}
+	switch t.focus {
+	case paneFeeds:
+		cursor := clamp(t.feedCursor+delta, len(t.feeds))
+		if cursor != t.feedCursor {
+			t.feedCursor = cursor
+			t.postCursor = 0
+			t.postOffset = 0
+			t.posts = nil
+			t.filter()
+			t.bodyOffset = 0
+		}
+	case panePosts:
+		cursor := clamp(t.postCursor+delta, len(t.posts))
+		if cursor != t.postCursor {
+			t.postCursor = cursor
+			t.bodyOffset = 0
+		}
+	case paneBody:
+		t.bodyOffset = clamp(t.bodyOffset+delta, len(t.bodyLines(t.bodyWidth())))
+	}
+}
+
+// resize reads the terminal size and reports whether it changed.
+func (t *tui) resize() bool {
+	w, h, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || (w == t.width && h == t.height) {
+		return false
+	}
+	t.width, t.height = w, h
+	return true
+}
+
+func (t *tui) paneHeight() int {
+	return max(t.height-3, 1)
+}
+
+func (t *tui) feedWidth() int {
+	return max(t.width/5, 16)
+}
+
+func (t *tui) postWidth() int {
+	return max(t.width*2/5, 24)
+}
+
+func (t *tui) bodyWidth() int {
+	return max(t.width-t.feedWidth()-t.postWidth()-2, 10)
+}
+
+func (t *tui) bodyLines(width int) []string {
+	post, ok := t.selectedPost()
+	if !ok {
+		return []string{"No posts yet. Run 'gator agg' to fetch your feeds."}
+	}
+	lines := render.Wrap(nullTitle(post.Title), width-1, "")
+	lines = append(lines, post.FeedNames+" · "+post.PublishedAt.Local().Format("Mon, 02 Jan 2006 15:04"))
+	lines = append(lines, render.Wrap(post.Url, width-1, "")...)
+	lines = append(lines, "")
+	if post.Description.Valid {
+		lines = append(lines, strings.Split(render.HTMLToTextWithLinks(post.Description.String, width-1), "\n")...)
+	}
+	return lines
+}
+
+func (t *tui) draw() {
+	if t.width == 0 || t.height == 0 {
+		return
+	}
+	rows := t.paneHeight()
+	fw, pw, bw := t.feedWidth(), t.postWidth(), t.bodyWidth()
+
+	feedLines := make([]string, rows)
+	t.feedOffset = scrollOffset(t.feedCursor, t.feedOffset, rows)
+	for i := range feedLines {
+		idx := t.feedOffset + i
+		if idx >= len(t.feeds) {
+			feedLines[i] = fit("", fw)
+			continue
+		}
+		f := t.feeds[idx]
+		label := " " + f.name
+		if f.folder {
+			label = " ▸ " + f.name
+		} else if f.nested {
+			label = "   " + f.name
+		}
+		count := ""
+		if f.unread > 0 {
+			count = " " + strconv.Itoa(f.unread) + " "
+		}
+		line := fit(label, fw-len(count)) + count
+		feedLines[i] = highlight(line, idx == t.feedCursor, t.focus == paneFeeds)
+	}
+
+	postLines := make([]string, rows)
+	t.postOffset = scrollOffset(t.postCursor, t.postOffset, rows)
+	for i := range postLines {
+		idx := t.postOffset + i
+		if idx >= len(t.posts) {
+			postLines[i] = fit("", pw)
+			continue
+		}
+		p := t.posts[idx]
+		marker := " ● "
+		if p.IsRead {
+			marker = "   "
+		}
+		age := fmt.Sprintf(" %6s ", shortAge(p.PublishedAt, time.Now()))
+		line := marker + fit(nullTitle(p.Title), pw-3-len(age)) + age
+		if !p.IsRead {
+			line = "\x1b[1m" + line + "\x1b[22m"
+		}
+		postLines[i] = highlight(line, idx == t.postCursor, t.focus == panePosts)
+	}
+
+	body := t.bodyLines(bw)
+	bodyLines := make([]string, rows)
+	for i := range bodyLines {
+		idx := t.bodyOffset + i
+		line := ""
+		if idx < len(body) {
+			line = " " + body[idx]
+		}
+		bodyLines[i] = fit(line, bw)
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	unread := 0
+	if len(t.feeds) > 0 {
+		unread = t.feeds[0].unread
+	}
+	header := fmt.Sprintf(" gator · %s · %d unread", t.user.Name, unread)
+	b.WriteString("\x1b[7m" + fit(header, t.width) + "\x1b[0m\r\n")
+	titles := paneTitle(fit(" Feeds", fw), t.focus == paneFeeds) + "│" + paneTitle(fit(" Posts", pw), t.focus == panePosts) + "│" + paneTitle(fit(" Post", bw), t.focus == paneBody)
+	b.WriteString(titles + "\x1b[0m\x1b[K\r\n")
+	for i := 0; i < rows; i++ {
+		b.WriteString(feedLines[i] + "\x1b[0m│" + postLines[i] + "\x1b[0m│" + bodyLines[i] + "\x1b[K\r\n")
+	}
+	footer := t.status
+	if footer == "" {
+		footer = "↑↓ move  ←→ pane  enter read  m toggle read  o open in browser  r refresh  q quit"
+	}
+	b.WriteString("\x1b[2m" + fit(" "+footer, t.width) + "\x1b[0m")
+	os.Stdout.WriteString(b.String())
+}
+
+func paneTitle(title string, focused bool) string {
+	if focused {
+		return "\x1b[1;4m" + title + "\x1b[0m"
+	}
+	return title
+}
+
+func highlight(line string, selected, focused bool) string {
+	switch {
+	case selected && focused:
+		return "\x1b[7m" + line + "\x1b[0m"
+	case selected:
+		return "\x1b[4m" + line + "\x1b[0m"
+	}
+	return line
+}
+
+var controlChars = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ", "\x1b", "")
+
+// fit truncates or pads s to exactly width columns.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	s = output.Truncate(controlChars.Replace(s), width)
+	return s + strings.Repeat(" ", width-utf8.RuneCountInString(s))
+}
+
+func scrollOffset(cursor, offset, rows int) int {
+	if cursor < offset {
+		return cursor
+	}
+	if cursor >= offset+rows {
+		return cursor - rows + 1
+	}
+	return offset
+}
+
+func shortAge(t time.Time, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", max(int(d.Minutes()), 0))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+	return t.Local().Format("Jan 06")
+}
+
+func nullTitle(title sql.NullString) string {
+	if !title.Valid || strings.TrimSpace(title.String) == "" {
+		return "(untitled)"
+	}
+	return title.String
+}
+
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch {
+	case os.Getenv("BROWSER") != "":
+		cmd = exec.Command(os.Getenv("BROWSER"), url)
+	case runtime.GOOS == "darwin":
+		cmd = exec.Command("open", url)
+	case runtime.GOOS == "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// openInput returns the terminal to read keys from. A pending read on
+// /dev/tty ends when it is closed, unlike one on stdin; where there is no
+// /dev/tty, stdin is used and the reader stops after its next read.
+func openInput() (*os.File, bool) {
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		return tty, true
+	}
+	return os.Stdin, false
+}
+
+// readKeys decodes raw terminal input into key names and sends them on keys
+// until done is closed.
+func readKeys(r *os.File, keys chan<- string, done <-chan struct{}) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		in := buf[:n]
+		for len(in) > 0 {
+			key, size := decodeKey(in)
+			in = in[size:]
+			if key == "" {
+				continue
+			}
+			select {
+			case keys <- key:
+			case <-done:
+				return
+			}
+		}
+	}
+}
+
+var escapeKeys = map[string]string{
+	"[A": "up", "[B": "down", "[C": "right", "[D": "left",
+	"OA": "up", "OB": "down", "OC": "right", "OD": "left",
+	"[H": "home", "[F": "end", "[1~": "home", "[4~": "end",
+	"[5~": "pgup", "[6~": "pgdn", "[Z": "shift-tab",
+}
+
+func decodeKey(in []byte) (string, int) {
+	switch in[0] {
+	case 0x1b:
+		if len(in) > 2 && (in[1] == '[' || in[1] == 'O') {
+			end := 2
+			for end < len(in) && (in[end] < 0x40 || in[end] > 0x7e) {
+				end++
+			}
+			if end < len(in) {
+				return escapeKeys[string(in[1:end+1])], end + 1
+			}
+			return "", len(in)
+		}
+		return "esc", 1
+	case '\r', '\n':
+		return "enter", 1
+	case '\t':
+		return "tab", 1
+	case 0x03:
+		return "ctrl-c", 1
+	}
+	r, size := utf8.DecodeRune(in)
+	if r == utf8.RuneError {
+		return "", size
+	}
+	return string(r), size
+}